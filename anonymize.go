@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"localscan/scanner"
+)
+
+// anonymizer redacts the parts of results that identify a particular LAN,
+// for -anonymize. It keeps the structure: vendor prefixes of MACs, ports,
+// methods, and device types stay as they are.
+type anonymizer struct {
+	zeroIPs  bool         // also zero the host portion of IP addresses
+	networks []*net.IPNet // scanned networks, for the host mask of their IPs
+}
+
+// newAnonymizer returns an anonymizer for the plan's networks.
+func newAnonymizer(plan *scanner.Plan, zeroIPs bool) *anonymizer {
+	a := &anonymizer{zeroIPs: zeroIPs}
+	if plan.Interface != nil {
+		a.networks = plan.Interface.Networks
+	}
+	return a
+}
+
+// results returns anonymized copies of results.
+func (a *anonymizer) results(results []scanner.ScanResult) []scanner.ScanResult {
+	out := make([]scanner.ScanResult, len(results))
+	for i, r := range results {
+		out[i] = a.result(r)
+	}
+	return out
+}
+
+// result returns an anonymized copy of r. Banners, SNMP system
+// descriptions, Bonjour TXT metadata and Extra fields from an enrichment
+// hook are dropped since they often carry names, and IPv6 interface IDs
+// are always zeroed since they can embed the MAC.
+func (a *anonymizer) result(r scanner.ScanResult) scanner.ScanResult {
+	r.IP = a.ip(r.IP)
+	r.MAC = maskMAC(r.MAC)
+	r.Hostname = hashName(r.Hostname)
+	r.Target = hashName(r.Target)
+	r.Redirect = hashName(r.Redirect)
+	r.DeviceName = hashName(r.DeviceName)
+	r.Label = hashName(r.Label)
+	r.Banners = nil
+	r.Extra = nil
+	if r.Instances != nil {
+		instances := make([]scanner.ServiceInstance, len(r.Instances))
+		for i, inst := range r.Instances {
+			instances[i] = scanner.ServiceInstance{Type: inst.Type, Name: hashName(inst.Name), Host: hashName(inst.Host), Port: inst.Port}
+		}
+		r.Instances = instances
+	}
+	if r.SNMP != nil {
+		snmp := *r.SNMP
+		snmp.SysDescr = ""
+		if snmp.Community != scanner.DefaultSNMPCommunity {
+			snmp.Community = "redacted"
+		}
+		r.SNMP = &snmp
+	}
+	if r.Addresses != nil {
+		addrs := make([]net.IP, len(r.Addresses))
+		for i, ip := range r.Addresses {
+			addrs[i] = a.ip(ip)
+		}
+		r.Addresses = addrs
+	}
+	return r
+}
+
+// errors returns the probe errors with anonymized addresses.
+func (a *anonymizer) errors(errs []scanner.HostError) []scanner.HostError {
+	out := make([]scanner.HostError, len(errs))
+	for i, e := range errs {
+		out[i] = scanner.HostError{IP: a.ip(e.IP), Err: e.Err}
+	}
+	return out
+}
+
+// checks returns the port checks with anonymized addresses.
+func (a *anonymizer) checks(checks []scanner.PortCheck) []scanner.PortCheck {
+	out := make([]scanner.PortCheck, len(checks))
+	for i, c := range checks {
+		c.IP = a.ip(c.IP)
+		out[i] = c
+	}
+	return out
+}
+
+// meta anonymizes the addresses and hostname targets in the scan metadata.
+func (a *anonymizer) meta(m scanner.ScanMeta) scanner.ScanMeta {
+	m.LocalIP = a.ipString(m.LocalIP)
+	m.SourceIP = a.ipString(m.SourceIP)
+	targets := make([]string, len(m.Targets))
+	for i, t := range m.Targets {
+		port := ""
+		if host, p, err := net.SplitHostPort(t); err == nil {
+			t, port = host, p
+		}
+		switch {
+		case net.ParseIP(t) != nil:
+			targets[i] = a.ipString(t)
+		case strings.ContainsAny(t, "/-"):
+			targets[i] = t // CIDRs and ranges describe the network, not a host
+		default:
+			targets[i] = hashName(t)
+		}
+		if port != "" {
+			targets[i] = net.JoinHostPort(targets[i], port)
+		}
+	}
+	m.Targets = targets
+	return m
+}
+
+// ip zeroes the host portion of ip with -anonymize-ips, using the mask of
+// the scanned network that contains it (/24 for other IPv4 addresses). The
+// interface ID of IPv6 addresses is always zeroed.
+func (a *anonymizer) ip(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128))
+	}
+	if !a.zeroIPs {
+		return ip
+	}
+	for _, n := range a.networks {
+		if n.Contains(ip) {
+			return ip.Mask(n.Mask)
+		}
+	}
+	return ip.Mask(net.CIDRMask(24, 32))
+}
+
+func (a *anonymizer) ipString(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return a.ip(ip).String()
+	}
+	return s
+}
+
+// maskMAC keeps the vendor prefix (first 3 octets) of mac and masks the
+// rest, e.g. "AA:BB:CC:XX:XX:XX".
+func maskMAC(mac string) string {
+	if len(mac) != 17 {
+		return mac
+	}
+	sep := mac[2:3]
+	return mac[:8] + strings.Repeat(sep+"XX", 3)
+}
+
+// hashName replaces a name with a short hash, so the same name maps to the
+// same token across hosts and scans. Empty names and the "-" placeholder
+// are kept.
+func hashName(name string) string {
+	if name == "" || name == "-" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(name)))
+	return "host-" + hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"localscan/scanner"
+)
+
+// config is the on-disk configuration file (~/.localscan/config.json).
+type config struct {
+	// UDPPayloads maps a UDP port to a hex-encoded probe packet.
+	UDPPayloads map[string]string `json:"udp_payloads"`
+
+	// TCPPayloads maps a TCP port to the text sent to elicit a banner.
+	TCPPayloads map[string]string `json:"tcp_payloads"`
+
+	// Router holds the login for -router-hostnames.
+	Router struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"router"`
+
+	// DeviceTypes are extra vendor/port classification rules, tried before
+	// the built-in ones.
+	DeviceTypes []scanner.DeviceRule `json:"device_types"`
+
+	// VendorPrefixes maps MAC prefixes to vendor names that the built-in
+	// OUI table lacks or gets wrong.
+	VendorPrefixes map[string]string `json:"vendor_prefixes"`
+}
+
+// cfgOrDefault returns path, or the default config path if path is empty.
+func cfgOrDefault(path string) string {
+	if path != "" {
+		return path
+	}
+	return filepath.Join(scanner.DataDir(), "config.json")
+}
+
+// loadConfig reads the config file at path. A missing file is only an
+// error when the path was given explicitly.
+func loadConfig(path string, explicit bool) (*config, error) {
+	cfg := &config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// apply validates the config and copies its settings into opts.
+func (c *config) apply(opts *scanner.ScanOptions) error {
+	for key, value := range c.UDPPayloads {
+		port, err := parsePort(key)
+		if err != nil {
+			return fmt.Errorf("udp_payloads: %w", err)
+		}
+		payload, err := parseHex(value)
+		if err != nil {
+			return fmt.Errorf("udp_payloads[%s]: %w", key, err)
+		}
+		if opts.UDPPayloads == nil {
+			opts.UDPPayloads = make(map[int][]byte)
+		}
+		opts.UDPPayloads[port] = payload
+	}
+	for key, value := range c.TCPPayloads {
+		port, err := parsePort(key)
+		if err != nil {
+			return fmt.Errorf("tcp_payloads: %w", err)
+		}
+		if opts.TCPPayloads == nil {
+			opts.TCPPayloads = make(map[int][]byte)
+		}
+		opts.TCPPayloads[port] = []byte(value)
+	}
+	opts.Router = scanner.RouterCredentials{Username: c.Router.Username, Password: c.Router.Password}
+	for i, rule := range c.DeviceTypes {
+		if rule.Vendor == "" || rule.Type == "" {
+			return fmt.Errorf("device_types[%d]: vendor and type are required", i)
+		}
+		for _, port := range rule.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("device_types[%d]: invalid port %d", i, port)
+			}
+		}
+	}
+	opts.DeviceRules = c.DeviceTypes
+	for prefix, vendor := range c.VendorPrefixes {
+		key, ok := scanner.NormalizeMACPrefix(prefix)
+		if !ok || vendor == "" {
+			return fmt.Errorf("vendor_prefixes: invalid entry %q: %q", prefix, vendor)
+		}
+		if opts.Vendors == nil {
+			opts.Vendors = make(map[string]string)
+		}
+		opts.Vendors[key] = vendor
+	}
+	return nil
+}
+
+// parsePort parses a port number in the range 1-65535.
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// parsePortList parses a comma-separated list of ports and port ranges
+// ("53,123,5000-5010"). Duplicates are dropped; the order is kept.
+func parsePortList(s string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(item, "-")
+		first, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, fmt.Errorf("invalid port range %q", item)
+			}
+		}
+		for port := first; port <= last; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// parsePortTimeouts parses a comma-separated list of port=duration pairs
+// ("443=2s,554-555=1500ms"); a port range gives every port in it the same
+// timeout.
+func parsePortTimeouts(s string) (map[int]time.Duration, error) {
+	var timeouts map[int]time.Duration
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ports, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not port=duration", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q for %s", value, ports)
+		}
+		list, err := parsePortList(ports)
+		if err != nil {
+			return nil, err
+		}
+		if timeouts == nil {
+			timeouts = make(map[int]time.Duration)
+		}
+		for _, port := range list {
+			timeouts[port] = d
+		}
+	}
+	return timeouts, nil
+}
+
+// parseUDPProbe parses a -udp-probe spec of the form port:hexpayload:hexmatch.
+func parseUDPProbe(spec string) (scanner.UDPProbe, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return scanner.UDPProbe{}, fmt.Errorf("%q: want port:hexpayload:hexmatch", spec)
+	}
+	port, err := parsePort(parts[0])
+	if err != nil {
+		return scanner.UDPProbe{}, err
+	}
+	payload, err := parseHex(parts[1])
+	if err != nil {
+		return scanner.UDPProbe{}, fmt.Errorf("%q payload: %w", spec, err)
+	}
+	match, err := parseHex(parts[2])
+	if err != nil {
+		return scanner.UDPProbe{}, fmt.Errorf("%q match: %w", spec, err)
+	}
+	return scanner.UDPProbe{Port: port, Payload: payload, Match: match}, nil
+}
+
+// parseHex decodes a hex string, ignoring whitespace and an optional 0x prefix.
+func parseHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return nil, fmt.Errorf("empty payload")
+	}
+	return hex.DecodeString(s)
+}
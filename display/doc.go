@@ -0,0 +1,13 @@
+// Package display renders scanner results for people and for other tools.
+//
+// The writers take an io.Writer and the results, so they can be used
+// without the localscan CLI: PrintResults and PrintResultsCompact (tables),
+// PrintResultsJSON, WriteJSONL and PrintResultsJSONL, PrintResultsCSV,
+// PrintResultsIPs, PrintResultsIPPorts and PrintResultsSSHConfig. The JSON
+// document layout is versioned separately by OutputVersion.
+//
+// The live scan messages (PrintHeader, PrintProgress, PrintFound,
+// PrintComplete, PrintPhases) go to stderr unless redirected with SetProgressOutput or
+// silenced with SetQuiet. SetShowConfidence and SetPortOrder change
+// package-wide settings and are meant to be called once at startup.
+package display
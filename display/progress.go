@@ -1,45 +1,214 @@
 package display
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
 
 	"localscan/scanner"
 )
 
-const barWidth = 40
+// progressOut receives the live scan messages (header, progress, found).
+var progressOut io.Writer = os.Stderr
+
+// showConfidence adds a Conf column to table and compact output.
+var showConfidence bool
+
+// SetShowConfidence enables the Conf column in table and compact output.
+func SetShowConfidence(show bool) {
+	showConfidence = show
+}
+
+// showRTT adds an RTT column with the latency bucket to table and compact
+// output.
+var showRTT bool
+
+// SetShowRTT enables the RTT column in table and compact output.
+func SetShowRTT(show bool) {
+	showRTT = show
+}
+
+// diffIgnoreRoles are the roles left out of diff statuses, whose hosts are
+// not counted as unchanged either.
+var diffIgnoreRoles []string
+
+// SetDiffIgnore sets the roles passed to scanner.ComputeDiffIgnoring, so
+// that diff summaries leave their hosts out as the diff output does.
+func SetDiffIgnore(roles []string) {
+	diffIgnoreRoles = roles
+}
+
+// portsByRisk lists open ports riskiest first instead of by number.
+var portsByRisk bool
+
+// SetPortOrder sets how open ports are listed in table, compact and CSV
+// output: "number" (ascending) or "risk" (riskiest services first).
+func SetPortOrder(order string) error {
+	switch order {
+	case "number":
+		portsByRisk = false
+	case "risk":
+		portsByRisk = true
+	default:
+		return fmt.Errorf("unknown port order %q (want number or risk)", order)
+	}
+	return nil
+}
+
+// SetProgressOutput sends the live scan messages to w instead of stderr.
+// Colors are only used on stderr.
+func SetProgressOutput(w io.Writer) {
+	progressOut = w
+}
+
+// SetQuiet suppresses the live scan messages on stderr when quiet is true.
+func SetQuiet(quiet bool) {
+	if quiet {
+		progressOut = io.Discard
+	} else {
+		progressOut = os.Stderr
+	}
+}
 
 // PrintHeader prints the scan start message.
 func PrintHeader(cidr string, total int) {
-	fmt.Fprintf(os.Stderr, "Scanning %s (%d hosts)...\n", cidr, total)
+	fmt.Fprintf(progressOut, "Scanning %s (%d hosts)...\n", cidr, total)
 }
 
 // PrintProgress updates the progress bar on stderr.
 func PrintProgress(current, total int, ip string) {
+	text := fmt.Sprintf(" %d/%d scanning %s...   ", current, total, ip)
+	width := barSize(len(text) + 2)
 	pct := float64(current) / float64(total)
-	filled := int(pct * barWidth)
-	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
-	fmt.Fprintf(os.Stderr, "\r[%s] %d/%d scanning %s...   ", bar, current, total, ip)
+	filled := int(pct * float64(width))
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	fmt.Fprintf(progressOut, "\r[%s]%s", bar, text)
+}
+
+// methodColors are the ANSI colors of each detection method in the live
+// discovery messages.
+var methodColors = map[string]string{
+	"ARP":  "\033[36m", // cyan
+	"TCP":  "\033[32m", // green
+	"UDP":  "\033[33m", // yellow
+	"ICMP": "\033[34m", // blue
+	"SELF": "\033[35m", // magenta
 }
 
-// PrintFound prints a discovery message on stderr.
+// PrintFound prints a discovery message on stderr. The IP is padded so
+// consecutive messages line up, the method is colored on a terminal, and
+// the hostname and vendor follow when the result is already enriched.
 func PrintFound(result *scanner.ScanResult) {
-	fmt.Fprintf(os.Stderr, "\r\033[K[+] Found: %s [%s]\n", result.IP, result.Method)
+	method := fmt.Sprintf("%-6s", "["+result.Method+"]")
+	if c, ok := methodColors[result.Method]; ok && progressOut == os.Stderr && colorEnabled() {
+		method = c + method + "\033[0m"
+	}
+	line := fmt.Sprintf("[+] Found: %-15s %s", result.IP, method)
+	for _, s := range []string{result.Hostname, result.Vendor} {
+		if s != "" && s != "-" {
+			line += "  " + s
+		}
+	}
+	fmt.Fprintf(progressOut, "\r\033[K%s\n", strings.TrimRight(line, " "))
 }
 
 // PrintComplete clears the progress line and prints completion.
 func PrintComplete(total int) {
-	bar := strings.Repeat("=", barWidth)
-	fmt.Fprintf(os.Stderr, "\r[%s] %d/%d Complete\n\n", bar, total, total)
+	text := fmt.Sprintf(" %d/%d Complete", total, total)
+	bar := strings.Repeat("=", barSize(len(text)+2))
+	fmt.Fprintf(progressOut, "\r\033[K[%s]%s\n\n", bar, text)
 }
 
-// formatPorts returns a comma-separated string of port numbers.
+// PrintPhases prints how long each pass of a two-phase scan took.
+func PrintPhases(phases []scanner.ScanPhase) {
+	for i, ph := range phases {
+		fmt.Fprintf(progressOut, "Phase %d (%s): %d of %d hosts answered in %s\n",
+			i+1, ph.Name, ph.Found, ph.Hosts, ph.Elapsed.Round(100*time.Millisecond))
+	}
+	if len(phases) > 0 {
+		fmt.Fprintln(progressOut)
+	}
+}
+
+// portRisk ranks services by how much attention an exposed port deserves,
+// from 1 (riskiest) up: cleartext logins and remote desktops, then file
+// sharing and databases, then other management interfaces. Unlisted ports
+// rank after all of these.
+var portRisk = map[int]int{
+	23:    1, // Telnet
+	445:   1, // SMB
+	3389:  1, // RDP
+	5900:  1, // VNC
+	21:    2, // FTP
+	139:   2, // NetBIOS session
+	135:   2, // MS RPC
+	1433:  3, // MS SQL
+	3306:  3, // MySQL
+	5432:  3, // PostgreSQL
+	6379:  3, // Redis
+	27017: 3, // MongoDB
+	161:   4, // SNMP
+	1883:  4, // MQTT
+	2375:  4, // Docker API
+	9100:  4, // raw printing
+	22:    5, // SSH
+	80:    6, // HTTP
+	8080:  6, // HTTP alternate
+}
+
+// riskRank returns the portRisk rank of port.
+func riskRank(port int) int {
+	if rank, ok := portRisk[port]; ok {
+		return rank
+	}
+	return len(portRisk) + 1
+}
+
+// formatRTT formats r's round-trip time with its latency bucket, e.g.
+// "0.84ms wired", or "-" if it is unknown.
+func formatRTT(r scanner.ScanResult) string {
+	if r.RTT <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fms %s", rttMillis(r.RTT), scanner.LatencyBucket(r.RTT))
+}
+
+// rttMillis converts d to milliseconds rounded to 0.01.
+func rttMillis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
+
+// formatPortChanges returns the ports that opened and closed since the
+// previous scan, e.g. " (+8080 -23)", or "" if there are none.
+func formatPortChanges(d *scanner.PortDiff) string {
+	if d == nil {
+		return ""
+	}
+	var marks []string
+	for _, p := range d.Opened {
+		marks = append(marks, "+"+strconv.Itoa(p))
+	}
+	for _, p := range d.Closed {
+		marks = append(marks, "-"+strconv.Itoa(p))
+	}
+	return " (" + strings.Join(marks, " ") + ")"
+}
+
+// formatPorts returns a comma-separated string of port numbers, ascending
+// or, with SetPortOrder("risk"), riskiest first and ascending within a rank.
 func formatPorts(ports []int) string {
 	if len(ports) == 0 {
 		return "-"
@@ -47,6 +216,9 @@ func formatPorts(ports []int) string {
 	sorted := make([]int, len(ports))
 	copy(sorted, ports)
 	sort.Ints(sorted)
+	if portsByRisk {
+		sort.SliceStable(sorted, func(i, j int) bool { return riskRank(sorted[i]) < riskRank(sorted[j]) })
+	}
 	parts := make([]string, len(sorted))
 	for i, p := range sorted {
 		parts[i] = strconv.Itoa(p)
@@ -54,6 +226,121 @@ func formatPorts(ports []int) string {
 	return strings.Join(parts, ",")
 }
 
+// column is one column of the results table.
+type column struct {
+	title string
+	width int
+	value func(r scanner.ScanResult) string
+}
+
+// resultColumns returns the columns to print for results, each wide enough
+// for its header and every value. Status is only included when some result
+// has a diff status, Label only when some host has a label, Type only when
+// some device was classified, First Seen only when seen times are known,
+// and Conf only when enabled with SetShowConfidence.
+func resultColumns(results []scanner.ScanResult) []column {
+	cols := []column{
+		{title: "IP Address", value: func(r scanner.ScanResult) string { return formatIP(r.IP) }},
+		{title: "Hostname", value: func(r scanner.ScanResult) string { return r.Hostname }},
+	}
+
+	hasDiff, hasSeen, hasLabel, hasType, hasV6 := false, false, false, false, false
+	for _, r := range results {
+		hasV6 = hasV6 || len(r.Addresses) > 1
+		hasDiff = hasDiff || r.Status != "" || r.Unexpected
+		hasSeen = hasSeen || !r.FirstSeen.IsZero()
+		hasLabel = hasLabel || r.Label != ""
+		hasType = hasType || r.DeviceType != ""
+	}
+	if hasV6 {
+		cols = append(cols[:1], append([]column{{title: "IPv6", value: formatIPv6}}, cols[1:]...)...)
+	}
+	if hasLabel {
+		cols = append(cols, column{title: "Label", value: func(r scanner.ScanResult) string { return orDash(r.Label) }})
+	}
+	cols = append(cols,
+		column{title: "MAC Address", value: func(r scanner.ScanResult) string { return r.MAC }},
+		column{title: "Vendor", value: func(r scanner.ScanResult) string { return r.Vendor }},
+	)
+	if hasType {
+		cols = append(cols, column{title: "Type", value: func(r scanner.ScanResult) string { return orDash(r.DeviceType) }})
+	}
+	cols = append(cols,
+		column{title: "Method", value: func(r scanner.ScanResult) string { return r.Method }},
+	)
+	if showConfidence {
+		cols = append(cols, column{title: "Conf", value: func(r scanner.ScanResult) string { return formatConfidence(r) }})
+	}
+	if showRTT {
+		cols = append(cols, column{title: "RTT", value: formatRTT})
+	}
+	cols = append(cols,
+		column{title: "Ports", value: func(r scanner.ScanResult) string { return formatPorts(r.OpenPorts) + formatPortChanges(r.PortChanges) }},
+	)
+	if hasSeen {
+		cols = append(cols, column{title: "First Seen", value: func(r scanner.ScanResult) string { return formatSeen(r.FirstSeen) }})
+	}
+	if hasDiff {
+		cols = append(cols, column{title: "Status", value: formatStatus})
+	}
+
+	for i := range cols {
+		cols[i].width = len(cols[i].title)
+		for _, r := range results {
+			cols[i].width = max(cols[i].width, len(cols[i].value(r)))
+		}
+	}
+	return cols
+}
+
+// formatStatus returns the diff status of r followed by UNKNOWN if the host
+// is not on the expected-devices list, e.g. "NEW UNKNOWN".
+func formatStatus(r scanner.ScanResult) string {
+	if !r.Unexpected {
+		return r.Status
+	}
+	return strings.TrimSpace(r.Status + " UNKNOWN")
+}
+
+// formatIP formats ip, or returns "-" for a missing device whose address
+// is unknown.
+func formatIP(ip net.IP) string {
+	if ip == nil {
+		return "-"
+	}
+	return ip.String()
+}
+
+// absent reports whether r is listed for a host that did not answer.
+func absent(r scanner.ScanResult) bool {
+	return r.Status == "GONE" || r.Status == "MISSING"
+}
+
+// formatConfidence formats r's confidence score, or "-" for hosts that were
+// not detected in this scan (GONE, MISSING).
+func formatConfidence(r scanner.ScanResult) string {
+	if absent(r) {
+		return "-"
+	}
+	return strconv.Itoa(r.Confidence)
+}
+
+// orDash returns s, or "-" if s is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatSeen formats a first/last seen time in local time, or "-" if unknown.
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
 // PrintResults prints the final results table to the given writer.
 func PrintResults(w io.Writer, results []scanner.ScanResult, elapsed string) {
 	if len(results) == 0 {
@@ -61,202 +348,791 @@ func PrintResults(w io.Writer, results []scanner.ScanResult, elapsed string) {
 		return
 	}
 
-	// Calculate column widths
-	maxIP, maxHost, maxMAC, maxVendor, maxMethod, maxPorts, maxStatus := 10, 8, 11, 6, 6, 5, 6
+	cols := resultColumns(results)
+	numW := len(strconv.Itoa(len(results)))
+
+	var sep, header strings.Builder
+	sep.WriteString("+-" + strings.Repeat("-", numW+2) + "-+")
+	header.WriteString("| " + padCenter("#", numW+2) + " |")
+	for _, c := range cols {
+		sep.WriteString("-" + strings.Repeat("-", c.width) + "-+")
+		fmt.Fprintf(&header, " %-*s |", c.width, c.title)
+	}
+
+	fmt.Fprintln(w, sep.String())
+	fmt.Fprintln(w, header.String())
+	fmt.Fprintln(w, sep.String())
+
+	for i, r := range results {
+		fmt.Fprintf(w, "| %*d   |", numW, i+1)
+		for _, c := range cols {
+			fmt.Fprintf(w, " %-*s |", c.width, c.value(r))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, sep.String())
+	fmt.Fprintf(w, "Found %d devices in %s\n", len(results), elapsed)
+}
+
+// PrintResultsCompact prints one line per host in aligned columns without
+// borders, which is easier to grep and diff than the table.
+func PrintResultsCompact(w io.Writer, results []scanner.ScanResult, elapsed string) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No devices found.")
+		return
+	}
+
+	cols := resultColumns(results)
+	row := func(value func(c column) string) {
+		var line strings.Builder
+		for i, c := range cols {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			fmt.Fprintf(&line, "%-*s", c.width, value(c))
+		}
+		fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+	}
+
+	row(func(c column) string { return c.title })
 	for _, r := range results {
-		if len(r.IP.String()) > maxIP {
-			maxIP = len(r.IP.String())
+		row(func(c column) string { return c.value(r) })
+	}
+	fmt.Fprintf(w, "Found %d devices in %s\n", len(results), elapsed)
+}
+
+// PrintInterfaces lists network interfaces with their addresses and link
+// details. The interface named selected (the one a scan would use) is
+// marked with "*".
+func PrintInterfaces(w io.Writer, infos []*scanner.InterfaceInfo, selected string) {
+	if len(infos) == 0 {
+		fmt.Fprintln(w, "No active IPv4 interfaces found.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  Interface\tNetworks\tMAC Address\tMTU\tSpeed")
+	for _, info := range infos {
+		mark := " "
+		if info.Name == selected {
+			mark = "*"
 		}
-		if len(r.Hostname) > maxHost {
-			maxHost = len(r.Hostname)
+		mac := info.MAC
+		if mac == "" {
+			mac = "-"
 		}
-		if len(r.MAC) > maxMAC {
-			maxMAC = len(r.MAC)
+		speed := "-"
+		if info.Speed > 0 {
+			speed = fmt.Sprintf("%d Mbit/s", info.Speed)
 		}
-		if len(r.Vendor) > maxVendor {
-			maxVendor = len(r.Vendor)
+		name := info.Name
+		if info.Virtual {
+			name += " (virtual)"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%s\t%d\t%s\n", mark, name, info.CIDRs(), mac, info.MTU, speed)
+	}
+	tw.Flush()
+}
+
+// OutputVersion is the format version of the JSON output document.
+const OutputVersion = 1
+
+// jsonOutput is the top-level JSON output document.
+type jsonOutput struct {
+	Version     int                 `json:"version"`
+	Meta        *scanner.ScanMeta   `json:"scan,omitempty"`
+	Results     []jsonResult        `json:"results"`
+	PortSummary []PortCount         `json:"port_summary,omitempty"`
+	Errors      []jsonError         `json:"errors,omitempty"`
+	Checks      []scanner.PortCheck `json:"checks,omitempty"`
+	DiffSummary *DiffSummary        `json:"diff_summary,omitempty"`
+	Expected    *ExpectedSummary    `json:"expected_summary,omitempty"`
+	Coverage    *Coverage           `json:"coverage,omitempty"`
+	Stats       *jsonStats          `json:"stats,omitempty"`
+}
+
+// jsonStats is the "stats" section: the probe traffic estimate, plus the
+// address utilization when known.
+type jsonStats struct {
+	*scanner.ProbeStats
+	Utilization *Utilization `json:"utilization,omitempty"`
+}
+
+// jsonError is the JSON representation of a host probe error.
+type jsonError struct {
+	IP    string `json:"ip"`
+	Error string `json:"error"`
+}
+
+// JSONOptions selects optional sections of the JSON output document.
+type JSONOptions struct {
+	PortSummary bool                // include the network-wide open port counts
+	Errors      []scanner.HostError // probe errors for hosts that were not found
+	DiffSummary bool                // include counts of diff statuses
+	Expected    bool                // include counts of unknown and missing devices
+	Meta        *scanner.ScanMeta   // scan configuration, written before the results
+	Coverage    *Coverage           // how much of the target range was probed
+	Stats       *scanner.ProbeStats // estimate of the probe traffic sent
+	Utilization *Utilization        // addresses in use, written under stats
+	Checks      []scanner.PortCheck // states of the "host:port" targets
+}
+
+// PortCount is the number of hosts that have a given TCP port open.
+type PortCount struct {
+	Port  int `json:"port"`
+	Hosts int `json:"hosts"`
+}
+
+// CountPorts tallies how many hosts have each port open, sorted by host
+// count descending (ties broken by port number). GONE and MISSING hosts are
+// ignored.
+func CountPorts(results []scanner.ScanResult) []PortCount {
+	counts := make(map[int]int)
+	for _, r := range results {
+		if absent(r) {
+			continue
 		}
-		if len(r.Method) > maxMethod {
-			maxMethod = len(r.Method)
+		for _, p := range r.OpenPorts {
+			counts[p]++
 		}
-		portsStr := formatPorts(r.OpenPorts)
-		if len(portsStr) > maxPorts {
-			maxPorts = len(portsStr)
+	}
+
+	summary := make([]PortCount, 0, len(counts))
+	for port, n := range counts {
+		summary = append(summary, PortCount{Port: port, Hosts: n})
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Hosts != summary[j].Hosts {
+			return summary[i].Hosts > summary[j].Hosts
 		}
-		if len(r.Status) > maxStatus {
-			maxStatus = len(r.Status)
+		return summary[i].Port < summary[j].Port
+	})
+	return summary
+}
+
+// PrintPortSummary prints how many hosts have each port open.
+func PrintPortSummary(w io.Writer, results []scanner.ScanResult) {
+	summary := CountPorts(results)
+	if len(summary) == 0 {
+		fmt.Fprintln(w, "No open ports found.")
+		return
+	}
+
+	fmt.Fprintln(w, "Open ports across network:")
+	for _, pc := range summary {
+		unit := "hosts"
+		if pc.Hosts == 1 {
+			unit = "host"
 		}
+		fmt.Fprintf(w, "  %5d: %d %s\n", pc.Port, pc.Hosts, unit)
 	}
+}
+
+// DiffSummary counts results by diff status.
+type DiffSummary struct {
+	New       int `json:"new"`
+	Gone      int `json:"gone"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
 
-	// Check if any result has a diff status
-	hasDiff := false
+// CountDiff tallies the diff statuses of results. MISSING hosts are left to
+// CountExpected; an unexpected host is still counted by its diff status.
+// Hosts with a role set by SetDiffIgnore only count if they CHANGED.
+func CountDiff(results []scanner.ScanResult) DiffSummary {
+	var s DiffSummary
 	for _, r := range results {
-		if r.Status != "" {
-			hasDiff = true
-			break
+		switch r.Status {
+		case "NEW":
+			s.New++
+		case "GONE":
+			s.Gone++
+		case "CHANGED":
+			s.Changed++
+		case "MISSING":
+		default:
+			if !slices.Contains(diffIgnoreRoles, r.Role) {
+				s.Unchanged++
+			}
 		}
 	}
+	return s
+}
+
+// PrintDiffSummary prints a one-line count of diff statuses, e.g.
+// "3 new, 1 gone, 2 changed, 10 unchanged".
+func PrintDiffSummary(w io.Writer, results []scanner.ScanResult) {
+	s := CountDiff(results)
+	fmt.Fprintf(w, "%d new, %d gone, %d changed, %d unchanged\n", s.New, s.Gone, s.Changed, s.Unchanged)
+}
 
-	// Build format string
-	numW := len(fmt.Sprintf("%d", len(results)))
-	if numW < 1 {
-		numW = 1
+// ExpectedSummary counts hosts that deviate from the expected-devices list.
+type ExpectedSummary struct {
+	Unknown int `json:"unknown"`
+	Missing int `json:"missing"`
+}
+
+// CountExpected tallies the unexpected and MISSING hosts in results.
+func CountExpected(results []scanner.ScanResult) ExpectedSummary {
+	var s ExpectedSummary
+	for _, r := range results {
+		if r.Unexpected {
+			s.Unknown++
+		}
+		if r.Status == "MISSING" {
+			s.Missing++
+		}
 	}
+	return s
+}
 
-	if hasDiff {
-		sep := fmt.Sprintf("+-%s-+-%s-+-%s-+-%s-+-%s-+-%s-+-%s-+-%s-+",
-			strings.Repeat("-", numW+2),
-			strings.Repeat("-", maxIP),
-			strings.Repeat("-", maxHost),
-			strings.Repeat("-", maxMAC),
-			strings.Repeat("-", maxVendor),
-			strings.Repeat("-", maxMethod),
-			strings.Repeat("-", maxPorts),
-			strings.Repeat("-", maxStatus),
-		)
+// Coverage is how much of the target range a scan probed. A partial scan
+// has Complete false and Reason set to why it stopped early.
+type Coverage struct {
+	Scanned  int     `json:"scanned"`
+	Total    int     `json:"total"`
+	Percent  float64 `json:"percent"`
+	Complete bool    `json:"complete"`
+	Reason   string  `json:"reason,omitempty"`
+}
 
-		header := fmt.Sprintf("| %s | %-*s | %-*s | %-*s | %-*s | %-*s | %-*s | %-*s |",
-			padCenter("#", numW+2),
-			maxIP, "IP Address",
-			maxHost, "Hostname",
-			maxMAC, "MAC Address",
-			maxVendor, "Vendor",
-			maxMethod, "Method",
-			maxPorts, "Ports",
-			maxStatus, "Status",
-		)
+// NewCoverage summarizes the coverage of a scan report.
+func NewCoverage(r *scanner.Report) Coverage {
+	c := Coverage{
+		Scanned:  r.Scanned,
+		Total:    r.Total,
+		Percent:  math.Round(r.Coverage()*10) / 10,
+		Complete: r.Interrupted == nil && r.Complete(),
+	}
+	switch {
+	case c.Complete:
+	case errors.Is(r.Interrupted, context.DeadlineExceeded):
+		c.Reason = "deadline reached"
+	case errors.Is(r.Interrupted, context.Canceled):
+		c.Reason = "interrupted"
+	case r.Interrupted != nil:
+		c.Reason = r.Interrupted.Error()
+	default:
+		c.Reason = "stopped early"
+	}
+	return c
+}
 
-		fmt.Fprintln(w, sep)
-		fmt.Fprintln(w, header)
-		fmt.Fprintln(w, sep)
+// String formats c as "scanned 4321/65534 hosts (6.6%)".
+func (c Coverage) String() string {
+	return fmt.Sprintf("scanned %d/%d hosts (%.1f%%)", c.Scanned, c.Total, c.Percent)
+}
 
-		for i, r := range results {
-			fmt.Fprintf(w, "| %*d   | %-*s | %-*s | %-*s | %-*s | %-*s | %-*s | %-*s |\n",
-				numW, i+1,
-				maxIP, r.IP.String(),
-				maxHost, r.Hostname,
-				maxMAC, r.MAC,
-				maxVendor, r.Vendor,
-				maxMethod, r.Method,
-				maxPorts, formatPorts(r.OpenPorts),
-				maxStatus, r.Status,
-			)
+// PrintSNMPFindings lists the hosts whose SNMP agent answered one of the
+// audited community strings. Nothing is written if none did.
+func PrintSNMPFindings(w io.Writer, results []scanner.ScanResult) {
+	var found []scanner.ScanResult
+	for _, r := range results {
+		if r.SNMP != nil {
+			found = append(found, r)
 		}
+	}
+	if len(found) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "SNMP communities accepted (%d):\n", len(found))
+	for _, r := range found {
+		fmt.Fprintf(w, "  %-15s  v%-2s  %-12q  %s\n", formatIP(r.IP), r.SNMP.Version, r.SNMP.Community, r.SNMP.SysDescr)
+	}
+}
 
-		fmt.Fprintln(w, sep)
-	} else {
-		sep := fmt.Sprintf("+-%s-+-%s-+-%s-+-%s-+-%s-+-%s-+-%s-+",
-			strings.Repeat("-", numW+2),
-			strings.Repeat("-", maxIP),
-			strings.Repeat("-", maxHost),
-			strings.Repeat("-", maxMAC),
-			strings.Repeat("-", maxVendor),
-			strings.Repeat("-", maxMethod),
-			strings.Repeat("-", maxPorts),
-		)
+// PrintChecks lists the state of each port of the "host:port" targets.
+// Nothing is written if there are none.
+func PrintChecks(w io.Writer, checks []scanner.PortCheck) {
+	if len(checks) == 0 {
+		return
+	}
+	up := 0
+	for _, c := range checks {
+		if c.Up() {
+			up++
+		}
+	}
+	fmt.Fprintf(w, "Port checks (%d/%d open):\n", up, len(checks))
+	for _, c := range checks {
+		fmt.Fprintf(w, "  %-21s  %s\n", net.JoinHostPort(formatIP(c.IP), strconv.Itoa(c.Port)), c.State)
+	}
+}
 
-		header := fmt.Sprintf("| %s | %-*s | %-*s | %-*s | %-*s | %-*s | %-*s |",
-			padCenter("#", numW+2),
-			maxIP, "IP Address",
-			maxHost, "Hostname",
-			maxMAC, "MAC Address",
-			maxVendor, "Vendor",
-			maxMethod, "Method",
-			maxPorts, "Ports",
-		)
+// PrintProbeStats writes the estimated probe traffic of a scan.
+func PrintProbeStats(w io.Writer, s scanner.ProbeStats) {
+	fmt.Fprintf(w, "Sent ~%d packets (~%s): %d TCP connects, %d UDP, %d ICMP\n",
+		s.Packets, formatBytes(s.Bytes), s.TCPConnects, s.UDPPackets, s.ICMPEchoes)
+}
 
-		fmt.Fprintln(w, sep)
-		fmt.Fprintln(w, header)
-		fmt.Fprintln(w, sep)
+// Utilization is the share of the scanned addresses that are in use.
+type Utilization struct {
+	InUse   int     `json:"in_use"`
+	Total   int     `json:"total"`
+	Percent float64 `json:"percent"`
+}
 
-		for i, r := range results {
-			fmt.Fprintf(w, "| %*d   | %-*s | %-*s | %-*s | %-*s | %-*s | %-*s |\n",
-				numW, i+1,
-				maxIP, r.IP.String(),
-				maxHost, r.Hostname,
-				maxMAC, r.MAC,
-				maxVendor, r.Vendor,
-				maxMethod, r.Method,
-				maxPorts, formatPorts(r.OpenPorts),
-			)
-		}
+// NewUtilization returns the utilization of total scanned addresses, of
+// which found answered. Like HostsInNetwork, total leaves out the network
+// and broadcast addresses.
+func NewUtilization(found, total int) Utilization {
+	u := Utilization{InUse: found, Total: total}
+	if total > 0 {
+		u.Percent = math.Round(float64(found)/float64(total)*1000) / 10
+	}
+	return u
+}
 
-		fmt.Fprintln(w, sep)
+// PrintUtilization writes how many of the scanned addresses are in use,
+// e.g. "Address utilization: 23/254 addresses in use (9%)". Nothing is
+// written if no addresses were scanned.
+func PrintUtilization(w io.Writer, found, total int) {
+	if total <= 0 {
+		return
 	}
+	fmt.Fprintf(w, "Address utilization: %d/%d addresses in use (%.0f%%)\n", found, total, NewUtilization(found, total).Percent)
+}
 
-	fmt.Fprintf(w, "Found %d devices in %s\n", len(results), elapsed)
+// formatBytes formats n bytes with a binary unit, e.g. "12.3 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// PrintCoverage writes a banner for a partial scan, so the results are not
+// mistaken for the whole network. Nothing is written for a complete scan.
+func PrintCoverage(w io.Writer, c Coverage) {
+	if c.Complete {
+		return
+	}
+	fmt.Fprintf(w, "*** PARTIAL SCAN: %s, %s ***\n", c, c.Reason)
+}
+
+// PrintExpectedSummary lists the hosts that are not on the expected-devices
+// list and the expected devices that were not found.
+func PrintExpectedSummary(w io.Writer, results []scanner.ScanResult) {
+	s := CountExpected(results)
+	if s.Unknown == 0 && s.Missing == 0 {
+		fmt.Fprintln(w, "All devices are expected.")
+		return
+	}
+	if s.Unknown > 0 {
+		fmt.Fprintf(w, "Unexpected devices (%d):\n", s.Unknown)
+		for _, r := range results {
+			if r.Unexpected {
+				fmt.Fprintf(w, "  %-15s  %-17s  %s\n", formatIP(r.IP), r.MAC, r.Vendor)
+			}
+		}
+	}
+	if s.Missing > 0 {
+		fmt.Fprintf(w, "Missing expected devices (%d):\n", s.Missing)
+		for _, r := range results {
+			if r.Status == "MISSING" {
+				fmt.Fprintf(w, "  %-15s  %-17s  %s\n", formatIP(r.IP), r.MAC, orDash(r.Label))
+			}
+		}
+	}
 }
 
 // jsonResult is the JSON representation of a scan result.
 type jsonResult struct {
-	IP        string `json:"ip"`
-	Hostname  string `json:"hostname"`
-	MAC       string `json:"mac"`
-	Vendor    string `json:"vendor"`
-	Method    string `json:"method"`
-	OpenPorts []int  `json:"open_ports"`
-	Status    string `json:"status,omitempty"`
+	IP          string                    `json:"ip"`
+	Hostname    string                    `json:"hostname"`
+	MAC         string                    `json:"mac"`
+	Vendor      string                    `json:"vendor"`
+	Method      string                    `json:"method"`
+	OpenPorts   []int                     `json:"open_ports"`
+	Status      string                    `json:"status,omitempty"`
+	Unexpected  bool                      `json:"unexpected,omitempty"`
+	PortChanges *scanner.PortDiff         `json:"port_changes,omitempty"`
+	Role        string                    `json:"role,omitempty"`
+	Target      string                    `json:"target,omitempty"`
+	Redirect    string                    `json:"http_redirect,omitempty"`
+	RandomMAC   bool                      `json:"random_mac,omitempty"`
+	DeviceName  string                    `json:"device_name,omitempty"`
+	Model       string                    `json:"model,omitempty"`
+	Services    []string                  `json:"services,omitempty"`
+	Instances   []scanner.ServiceInstance `json:"service_instances,omitempty"`
+	Label       string                    `json:"label,omitempty"`
+	DeviceType  string                    `json:"device_type,omitempty"`
+	Confidence  int                       `json:"confidence"`
+	Answers     map[string]int            `json:"answers,omitempty"`
+	RTTMillis   float64                   `json:"rtt_ms,omitempty"`
+	Latency     string                    `json:"latency,omitempty"`
+	Banners     map[int]string            `json:"banners,omitempty"`
+	RTSP        *scanner.RTSPInfo         `json:"rtsp,omitempty"`
+	SNMP        *scanner.SNMPInfo         `json:"snmp,omitempty"`
+	Addresses   []string                  `json:"addresses,omitempty"`
+	ClosedPorts []int                     `json:"closed_ports,omitempty"`
+	FirstSeen   string                    `json:"first_seen,omitempty"`
+	LastSeen    string                    `json:"last_seen,omitempty"`
+	Reachable   string                    `json:"reachability,omitempty"`
+	Extra       map[string]string         `json:"extra,omitempty"`
+}
+
+// jsonMinimal omits empty and unknown ("-") fields from JSON results.
+var jsonMinimal bool
+
+// SetJSONMinimal makes JSON and JSON Lines output leave out every empty
+// field of a result, including an unknown hostname, MAC or vendor and an
+// empty open port list, instead of writing them as "-" or [].
+func SetJSONMinimal(minimal bool) {
+	jsonMinimal = minimal
+}
+
+// jsonMinimalResult is jsonResult with every field omitted when empty,
+// for SetJSONMinimal.
+type jsonMinimalResult struct {
+	IP          string                    `json:"ip"`
+	Hostname    string                    `json:"hostname,omitempty"`
+	MAC         string                    `json:"mac,omitempty"`
+	Vendor      string                    `json:"vendor,omitempty"`
+	Method      string                    `json:"method"`
+	OpenPorts   []int                     `json:"open_ports,omitempty"`
+	Status      string                    `json:"status,omitempty"`
+	Unexpected  bool                      `json:"unexpected,omitempty"`
+	PortChanges *scanner.PortDiff         `json:"port_changes,omitempty"`
+	Role        string                    `json:"role,omitempty"`
+	Target      string                    `json:"target,omitempty"`
+	Redirect    string                    `json:"http_redirect,omitempty"`
+	RandomMAC   bool                      `json:"random_mac,omitempty"`
+	DeviceName  string                    `json:"device_name,omitempty"`
+	Model       string                    `json:"model,omitempty"`
+	Services    []string                  `json:"services,omitempty"`
+	Instances   []scanner.ServiceInstance `json:"service_instances,omitempty"`
+	Label       string                    `json:"label,omitempty"`
+	DeviceType  string                    `json:"device_type,omitempty"`
+	Confidence  int                       `json:"confidence,omitempty"`
+	Answers     map[string]int            `json:"answers,omitempty"`
+	RTTMillis   float64                   `json:"rtt_ms,omitempty"`
+	Latency     string                    `json:"latency,omitempty"`
+	Banners     map[int]string            `json:"banners,omitempty"`
+	RTSP        *scanner.RTSPInfo         `json:"rtsp,omitempty"`
+	SNMP        *scanner.SNMPInfo         `json:"snmp,omitempty"`
+	Addresses   []string                  `json:"addresses,omitempty"`
+	ClosedPorts []int                     `json:"closed_ports,omitempty"`
+	FirstSeen   string                    `json:"first_seen,omitempty"`
+	LastSeen    string                    `json:"last_seen,omitempty"`
+	Reachable   string                    `json:"reachability,omitempty"`
+	Extra       map[string]string         `json:"extra,omitempty"`
+}
+
+// MarshalJSON writes r as is, or as a jsonMinimalResult when minimal JSON
+// is enabled.
+func (r jsonResult) MarshalJSON() ([]byte, error) {
+	if !jsonMinimal {
+		type plain jsonResult // without this method
+		return json.Marshal(plain(r))
+	}
+	m := jsonMinimalResult(r)
+	m.Hostname, m.MAC, m.Vendor = unknownEmpty(m.Hostname), unknownEmpty(m.MAC), unknownEmpty(m.Vendor)
+	return json.Marshal(m)
+}
+
+// unknownEmpty returns "" for the "-" placeholder of an unknown value.
+func unknownEmpty(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// ipStrings formats ips, or returns nil if there are none.
+func ipStrings(ips []net.IP) []string {
+	var out []string
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
+
+// formatIPv6 returns the first IPv6 address of a dual-stack host, with a
+// count of any others, or "-".
+func formatIPv6(r scanner.ScanResult) string {
+	if len(r.Addresses) < 2 {
+		return "-"
+	}
+	if more := len(r.Addresses) - 2; more > 0 {
+		return fmt.Sprintf("%s (+%d)", r.Addresses[1], more)
+	}
+	return r.Addresses[1].String()
+}
+
+// formatRFC3339 formats t for JSON, or returns "" if t is zero.
+func formatRFC3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// toJSONResult converts a scan result to its JSON representation.
+func toJSONResult(r scanner.ScanResult) jsonResult {
+	ports := r.OpenPorts
+	if ports == nil {
+		ports = []int{}
+	}
+	return jsonResult{
+		IP:          formatIP(r.IP),
+		Hostname:    r.Hostname,
+		MAC:         r.MAC,
+		Vendor:      r.Vendor,
+		Method:      r.Method,
+		OpenPorts:   ports,
+		Status:      r.Status,
+		Unexpected:  r.Unexpected,
+		PortChanges: r.PortChanges,
+		Role:        r.Role,
+		Target:      r.Target,
+		Redirect:    r.Redirect,
+		RandomMAC:   r.RandomMAC,
+		DeviceName:  r.DeviceName,
+		Model:       r.Model,
+		Services:    r.Services,
+		Instances:   r.Instances,
+		Label:       r.Label,
+		DeviceType:  r.DeviceType,
+		Confidence:  r.Confidence,
+		Answers:     r.Answers,
+		RTTMillis:   rttMillis(r.RTT),
+		Latency:     scanner.LatencyBucket(r.RTT),
+		Banners:     r.Banners,
+		RTSP:        r.RTSP,
+		SNMP:        r.SNMP,
+		Addresses:   ipStrings(r.Addresses),
+		ClosedPorts: r.ClosedPorts,
+		FirstSeen:   formatRFC3339(r.FirstSeen),
+		LastSeen:    formatRFC3339(r.LastSeen),
+		Reachable:   r.Reachability,
+		Extra:       r.Extra,
+	}
 }
 
 // PrintResultsJSON writes scan results as JSON.
-func PrintResultsJSON(w io.Writer, results []scanner.ScanResult, elapsed string) {
+func PrintResultsJSON(w io.Writer, results []scanner.ScanResult, elapsed string, opts JSONOptions) {
 	out := make([]jsonResult, len(results))
 	for i, r := range results {
-		ports := r.OpenPorts
-		if ports == nil {
-			ports = []int{}
+		out[i] = toJSONResult(r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	doc := jsonOutput{Version: OutputVersion, Meta: opts.Meta, Results: out, Coverage: opts.Coverage, Checks: opts.Checks}
+	if opts.Stats != nil {
+		doc.Stats = &jsonStats{ProbeStats: opts.Stats, Utilization: opts.Utilization}
+	}
+	if opts.PortSummary {
+		doc.PortSummary = CountPorts(results)
+	}
+	if opts.DiffSummary {
+		s := CountDiff(results)
+		doc.DiffSummary = &s
+	}
+	if opts.Expected {
+		s := CountExpected(results)
+		doc.Expected = &s
+	}
+	for _, e := range opts.Errors {
+		doc.Errors = append(doc.Errors, jsonError{IP: e.IP.String(), Error: e.Err})
+	}
+	enc.Encode(doc)
+}
+
+// WriteJSONL writes r as a single line of JSON, for newline-delimited
+// output that can be produced while the scan runs.
+func WriteJSONL(w io.Writer, r scanner.ScanResult) error {
+	return json.NewEncoder(w).Encode(toJSONResult(r))
+}
+
+// PrintResultsJSONL writes scan results as JSON Lines, one host per line.
+func PrintResultsJSONL(w io.Writer, results []scanner.ScanResult) {
+	for _, r := range results {
+		WriteJSONL(w, r)
+	}
+}
+
+// jsonDiff is the JSON document written by PrintDiffJSON.
+type jsonDiff struct {
+	Version  int               `json:"version"`
+	Meta     *scanner.ScanMeta `json:"scan,omitempty"`
+	New      []jsonResult      `json:"new"`
+	Gone     []jsonResult      `json:"gone"`
+	Changed  []jsonResult      `json:"changed"`
+	Unknown  []jsonResult      `json:"unknown,omitempty"`
+	Missing  []jsonResult      `json:"missing,omitempty"`
+	Coverage *Coverage         `json:"coverage,omitempty"`
+}
+
+// PrintDiffJSON writes only the diff deltas as JSON, grouped as
+// {"new": [...], "gone": [...], "changed": [...]}. Unchanged hosts are left
+// out. The lists are empty rather than null when nothing changed. With an
+// expected-devices list, "unknown" and "missing" are added when non-empty.
+// meta, if not nil, is written as "scan" ahead of the lists.
+func PrintDiffJSON(w io.Writer, results []scanner.ScanResult, meta *scanner.ScanMeta, cov *Coverage) {
+	doc := jsonDiff{
+		Version:  OutputVersion,
+		Meta:     meta,
+		Coverage: cov,
+		New:      []jsonResult{},
+		Gone:     []jsonResult{},
+		Changed:  []jsonResult{},
+	}
+	for _, r := range results {
+		switch r.Status {
+		case "NEW":
+			doc.New = append(doc.New, toJSONResult(r))
+		case "GONE":
+			doc.Gone = append(doc.Gone, toJSONResult(r))
+		case "CHANGED":
+			doc.Changed = append(doc.Changed, toJSONResult(r))
+		case "MISSING":
+			doc.Missing = append(doc.Missing, toJSONResult(r))
 		}
-		out[i] = jsonResult{
-			IP:        r.IP.String(),
-			Hostname:  r.Hostname,
-			MAC:       r.MAC,
-			Vendor:    r.Vendor,
-			Method:    r.Method,
-			OpenPorts: ports,
-			Status:    r.Status,
+		if r.Unexpected {
+			doc.Unknown = append(doc.Unknown, toJSONResult(r))
 		}
 	}
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
-	enc.Encode(out)
+	enc.Encode(doc)
+}
+
+// CSVOptions controls the CSV dialect.
+type CSVOptions struct {
+	Comma    rune // field delimiter (',' if 0)
+	NoHeader bool // omit the header row, e.g. when appending to a file
+
+	// ScanTime, if set, is written in a leading ScanTime column, and the
+	// Status column is always included so that rows appended from
+	// different scans line up.
+	ScanTime time.Time
 }
 
 // PrintResultsCSV writes scan results as CSV.
-func PrintResultsCSV(w io.Writer, results []scanner.ScanResult, elapsed string) {
+func PrintResultsCSV(w io.Writer, results []scanner.ScanResult, elapsed string, opts CSVOptions) {
 	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
 
-	// Check if diff mode
-	hasDiff := false
+	// Check if diff mode; a scan log always has the Status column
+	logMode := !opts.ScanTime.IsZero()
+	hasDiff := logMode
 	for _, r := range results {
-		if r.Status != "" {
+		if r.Status != "" || r.Unexpected {
 			hasDiff = true
 			break
 		}
 	}
+	scanTime := opts.ScanTime.Format(time.RFC3339)
 
-	if hasDiff {
-		cw.Write([]string{"IP", "Hostname", "MAC", "Vendor", "Method", "OpenPorts", "Status"})
-	} else {
-		cw.Write([]string{"IP", "Hostname", "MAC", "Vendor", "Method", "OpenPorts"})
+	if !opts.NoHeader {
+		header := []string{"IP", "Hostname", "MAC", "Vendor", "Method", "OpenPorts"}
+		if logMode {
+			header = append([]string{"ScanTime"}, header...)
+		}
+		if hasDiff {
+			header = append(header, "Status")
+		}
+		cw.Write(header)
 	}
 
 	for _, r := range results {
-		row := []string{
-			r.IP.String(),
+		var row []string
+		if logMode {
+			row = append(row, scanTime)
+		}
+		row = append(row,
+			formatIP(r.IP),
 			r.Hostname,
 			r.MAC,
 			r.Vendor,
 			r.Method,
 			formatPorts(r.OpenPorts),
-		}
+		)
 		if hasDiff {
-			row = append(row, r.Status)
+			row = append(row, formatStatus(r))
 		}
 		cw.Write(row)
 	}
 	cw.Flush()
 }
 
+// PrintResultsIPs writes the IP address of each present host, one per line.
+func PrintResultsIPs(w io.Writer, results []scanner.ScanResult) {
+	for _, r := range results {
+		if absent(r) {
+			continue
+		}
+		fmt.Fprintln(w, r.IP)
+	}
+}
+
+// PrintResultsIPPorts writes one ip:port line per open port of each present host.
+func PrintResultsIPPorts(w io.Writer, results []scanner.ScanResult) {
+	for _, r := range results {
+		if absent(r) {
+			continue
+		}
+		ports := make([]int, len(r.OpenPorts))
+		copy(ports, r.OpenPorts)
+		sort.Ints(ports)
+		for _, p := range ports {
+			fmt.Fprintln(w, net.JoinHostPort(r.IP.String(), strconv.Itoa(p)))
+		}
+	}
+}
+
+// PrintResultsSSHConfig writes an ssh_config Host stanza for each present
+// host with port 22 open and a usable name: its label, or else its
+// hostname without the domain. Hosts without either are skipped. Aliases
+// are made unique by numbering repeats ("nas", "nas-2").
+func PrintResultsSSHConfig(w io.Writer, results []scanner.ScanResult) {
+	used := make(map[string]int)
+	for _, r := range results {
+		if absent(r) || !slices.Contains(r.OpenPorts, 22) {
+			continue
+		}
+		alias := sshAlias(r)
+		if alias == "" {
+			continue
+		}
+		used[alias]++
+		if n := used[alias]; n > 1 {
+			alias = fmt.Sprintf("%s-%d", alias, n)
+		}
+		fmt.Fprintf(w, "Host %s\n", alias)
+		if r.MAC != "" && r.MAC != "-" {
+			fmt.Fprintf(w, "    # %s %s\n", r.MAC, r.Vendor)
+		}
+		fmt.Fprintf(w, "    HostName %s\n\n", r.IP)
+	}
+}
+
+// sshAlias returns the ssh_config alias for r: its label or the first
+// part of its hostname, lower-cased with whitespace and characters ssh
+// treats as patterns replaced by "-", or "" if it has neither.
+func sshAlias(r scanner.ScanResult) string {
+	name := r.Label
+	if name == "" && r.Hostname != "" && r.Hostname != "-" {
+		name, _, _ = strings.Cut(r.Hostname, ".")
+	}
+	name = strings.Map(func(c rune) rune {
+		if unicode.IsSpace(c) || strings.ContainsRune("*?!,#\"'", c) {
+			return '-'
+		}
+		return unicode.ToLower(c)
+	}, strings.TrimSpace(name))
+	return strings.Trim(name, "-")
+}
+
 func padCenter(s string, width int) string {
 	if len(s) >= width {
 		return s
@@ -0,0 +1,84 @@
+package display
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"localscan/scanner"
+)
+
+func testResults() []scanner.ScanResult {
+	return []scanner.ScanResult{
+		{IP: net.ParseIP("192.168.1.10").To4(), OpenPorts: []int{443, 22}},
+		{IP: net.ParseIP("192.168.1.11").To4(), OpenPorts: []int{80}, Status: "GONE"},
+		{IP: net.ParseIP("192.168.1.12").To4(), Status: "NEW"},
+		{IP: net.ParseIP("192.168.1.13").To4(), OpenPorts: []int{8080}, Status: "MISSING"},
+	}
+}
+
+func TestPrintResultsIPs(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []scanner.ScanResult
+		want    string
+	}{
+		{"empty", nil, ""},
+		{"skips absent hosts", testResults(), "192.168.1.10\n192.168.1.12\n"},
+		{"only absent hosts", testResults()[1:2], ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		PrintResultsIPs(&buf, tt.results)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: PrintResultsIPs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrintResultsIPPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []scanner.ScanResult
+		want    string
+	}{
+		{"empty", nil, ""},
+		{"skips absent hosts, sorts ports", testResults(), "192.168.1.10:22\n192.168.1.10:443\n"},
+		{"only absent hosts", testResults()[1:2], ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		PrintResultsIPPorts(&buf, tt.results)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: PrintResultsIPPorts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCountDiffIncludesUnexpected(t *testing.T) {
+	results := append(testResults(),
+		scanner.ScanResult{IP: net.ParseIP("192.168.1.14").To4(), Status: "NEW", Unexpected: true},
+		scanner.ScanResult{IP: net.ParseIP("192.168.1.15").To4(), Unexpected: true},
+	)
+	if got, want := CountDiff(results), (DiffSummary{New: 2, Gone: 1, Unchanged: 2}); got != want {
+		t.Errorf("CountDiff = %+v, want %+v", got, want)
+	}
+	if got, want := CountExpected(results), (ExpectedSummary{Unknown: 2, Missing: 1}); got != want {
+		t.Errorf("CountExpected = %+v, want %+v", got, want)
+	}
+	if got := formatStatus(results[4]); got != "NEW UNKNOWN" {
+		t.Errorf("formatStatus = %q, want %q", got, "NEW UNKNOWN")
+	}
+}
+
+func TestCountDiffIgnoresRoles(t *testing.T) {
+	SetDiffIgnore([]string{"gateway"})
+	defer SetDiffIgnore(nil)
+	results := append(testResults(),
+		scanner.ScanResult{IP: net.ParseIP("192.168.1.1").To4(), Role: "gateway"},
+		scanner.ScanResult{IP: net.ParseIP("192.168.1.2").To4(), Role: "gateway", Status: "CHANGED"},
+	)
+	if got, want := CountDiff(results), (DiffSummary{New: 1, Gone: 1, Changed: 1, Unchanged: 1}); got != want {
+		t.Errorf("CountDiff = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package display
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+const (
+	defaultBarWidth = 40
+	minBarWidth     = 10
+)
+
+var (
+	termWidth    atomic.Int32 // 0 when stderr is not a terminal
+	termInitOnce sync.Once
+)
+
+// initTerminal reads the terminal width once and keeps it up to date on
+// resize where the platform supports it.
+func initTerminal() {
+	termInitOnce.Do(func() {
+		updateTermWidth()
+		watchResize(updateTermWidth)
+	})
+}
+
+func updateTermWidth() {
+	fd := int(os.Stderr.Fd())
+	if !term.IsTerminal(fd) {
+		termWidth.Store(0)
+		return
+	}
+	w, _, err := term.GetSize(fd)
+	if err != nil || w <= 0 {
+		termWidth.Store(0)
+		return
+	}
+	termWidth.Store(int32(w))
+}
+
+// barSize returns the progress bar width that fills the terminal line
+// alongside textLen characters of surrounding text, or the default width
+// when the terminal size is unknown.
+func barSize(textLen int) int {
+	initTerminal()
+	w := int(termWidth.Load())
+	if w == 0 {
+		return defaultBarWidth
+	}
+	// Leave the last column free so the line never wraps.
+	size := w - textLen - 1
+	if size < minBarWidth {
+		return minBarWidth
+	}
+	return size
+}
+
+// colorEnabled reports whether live messages may use ANSI colors: stderr
+// is a terminal and NO_COLOR (https://no-color.org) is not set.
+func colorEnabled() bool {
+	initTerminal()
+	return termWidth.Load() > 0 && os.Getenv("NO_COLOR") == ""
+}
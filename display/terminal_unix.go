@@ -0,0 +1,20 @@
+//go:build !windows
+
+package display
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls fn whenever the terminal is resized.
+func watchResize(fn func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
+	go func() {
+		for range ch {
+			fn()
+		}
+	}()
+}
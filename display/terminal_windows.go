@@ -0,0 +1,7 @@
+//go:build windows
+
+package display
+
+// watchResize is a no-op on Windows, which has no SIGWINCH; the width read
+// at startup is used for the whole scan.
+func watchResize(fn func()) {}
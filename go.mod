@@ -1,3 +1,7 @@
 module localscan
 
 go 1.24.4
+
+require golang.org/x/term v0.34.0
+
+require golang.org/x/sys v0.35.0 // indirect
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"localscan/scanner"
+)
+
+// openScanLog opens the audit log at path for appending, as one JSON
+// object per line. Records below info are dropped unless debug is set.
+// With an empty path the returned logger discards everything.
+func openScanLog(path string, debug bool) (*slog.Logger, io.Closer, error) {
+	if path == "" {
+		return slog.New(slog.DiscardHandler), io.NopCloser(nil), nil
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level})), f, nil
+}
+
+// planAttrs describes the scan configuration for the "scan started" record.
+func planAttrs(plan *scanner.Plan) []any {
+	meta := plan.Meta()
+	return []any{
+		"interface", meta.Interface,
+		"local_ip", meta.LocalIP,
+		"range", meta.Range,
+		"hosts", meta.Hosts,
+		"workers", meta.Workers,
+		"timeout_ms", meta.TimeoutMS,
+		"tcp_mode", meta.TCPMode,
+		"methods", meta.Methods,
+	}
+}
+
+// methodCounts returns a group with the number of hosts found by each
+// detection method, in method name order.
+func methodCounts(counts map[string]int) slog.Attr {
+	names := make([]string, 0, len(counts))
+	for m := range counts {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	attrs := make([]any, 0, len(names))
+	for _, m := range names {
+		attrs = append(attrs, slog.Int(m, counts[m]))
+	}
+	return slog.Group("found_by", attrs...)
+}
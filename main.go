@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"net"
 	"os"
-	"sort"
+	"os/signal"
+	"slices"
+	"strings"
 	"time"
 
 	"localscan/display"
+	"localscan/notify"
 	"localscan/scanner"
 )
 
@@ -21,65 +26,634 @@ func main() {
 		format    string
 		output    string
 		diff      bool
+		virtual   bool
+		portSum   bool
+		cfgPath   string
+		noSelf    bool
+		quiet     bool
+		tcpMode   string
+		portsBy   string
+		bcastPing bool
+		logFile   string
+		diffIgnIn string
+		banners   bool
+		sumLine   bool
+		limit     int
+		passive   bool
+		listen    time.Duration
+		discOnly  bool
+		jsonMin   bool
+		ouiFile   string
+		resetHist bool
+		jitterPct int
+		portTime  string
+		twoPhase  bool
+		fullEvery time.Duration
+		assumeYes bool
+		showRTT   bool
+		showVer   bool
+		vlan      int
+		noDNS     bool
+		dnsTime   time.Duration
+		increment bool
+		readLimit int
+		anonymize bool
+		anonIPs   bool
+		snmpComms string
+		snmpVer   string
+		reportErr bool
+		target    string
+		targetsIn string
+		dryRun    bool
+		httpProbe bool
+		csvDelim  string
+		csvNoHead bool
+		syslogTo  string
+		arpSettle int
+		deadline  time.Duration
+		mdnsSvcs  bool
+		minPorts  int
+		routerDNS bool
+		verbose   bool
+		recClosed bool
+		udpProbes []scanner.UDPProbe
+		listIfs   bool
+		labelsIn  string
+		diffOnly  bool
+		methods   string
+		expectIn  string
+		udpList   string
+		stream    bool
+		appendHst bool
+		csvAppend string
+		showConf  bool
+		sourceIP  string
+		selfTest  bool
 	)
 
 	flag.StringVar(&ifaceName, "interface", "", "Network interface to use (auto-detect if empty)")
 	flag.IntVar(&timeout, "timeout", 500, "Connection timeout in milliseconds")
-	flag.IntVar(&workers, "workers", 100, "Number of concurrent workers")
-	flag.StringVar(&format, "format", "table", "Output format: table, json, csv")
-	flag.StringVar(&output, "o", "", "Output file path (default: stdout)")
+	flag.IntVar(&workers, "workers", 0, "Number of concurrent workers (0 = scale with host count and CPUs)")
+	flag.StringVar(&format, "format", "table", "Output format(s), comma-separated: table, compact, json, jsonl, csv, ips, ip:port, ssh-config")
+	flag.StringVar(&output, "o", "", "Output file path, a directory, or one path per format; supports {date}, {time}, {datetime}, {cidr}, {format}; tcp://host:port or unix:///path.sock sends the output to a socket (default: stdout)")
 	flag.BoolVar(&diff, "diff", false, "Compare with previous scan results")
+	flag.BoolVar(&virtual, "include-virtual", false, "Allow auto-detection to pick VPN/virtual interfaces")
+	flag.BoolVar(&portSum, "port-summary", false, "Print how many hosts have each port open")
+	flag.StringVar(&cfgPath, "config", "", "Config file path (default: ~/.localscan/config.json)")
+	flag.BoolVar(&noSelf, "no-self", false, "Do not include the scanning host in results")
+	flag.BoolVar(&quiet, "quiet", false, "Suppress progress messages on stderr")
+	flag.StringVar(&tcpMode, "tcp", scanner.TCPConnect, "TCP probe mode: connect, syn (requires root)")
+	flag.BoolVar(&reportErr, "report-errors", false, "Include probe errors for hosts not found in JSON output")
+	flag.StringVar(&target, "target", "", "Comma-separated IPs, CIDRs, or hostnames to scan instead of the interface network; host:port checks only that TCP port")
+	flag.StringVar(&targetsIn, "targets-file", "", "File with targets to scan, one per line")
+	flag.BoolVar(&dryRun, "dry-run", false, "List the hosts that would be scanned and exit without probing")
+	flag.BoolVar(&httpProbe, "http", false, "Probe HTTP on port 80 for redirects (captive portals, login pages)")
+	flag.StringVar(&csvDelim, "csv-delim", ",", `CSV field delimiter (single character, or "\t" for tab)`)
+	flag.BoolVar(&csvNoHead, "csv-no-header", false, "Omit the CSV header row")
+	flag.StringVar(&syslogTo, "syslog", "", "Send each discovered host to a syslog collector (udp://host:514 or tcp://host:514)")
+	flag.IntVar(&arpSettle, "arp-settle", 500, "Time in milliseconds to re-read the ARP table for late resolutions (0 to disable)")
+	flag.DurationVar(&deadline, "deadline", 0, "Time budget for the whole scan including enrichment, e.g. 2m (0 = none)")
+	flag.BoolVar(&mdnsSvcs, "mdns-services", false, "Enumerate the Bonjour services each host advertises (JSON output)")
+	flag.IntVar(&minPorts, "min-ports", 0, "Only display hosts with at least this many open TCP ports")
+	flag.BoolVar(&routerDNS, "router-hostnames", false, "Fill unresolved hostnames from the router's DHCP leases (OpenWrt, FRITZ!Box)")
+	flag.BoolVar(&verbose, "v", false, "Verbose output on stderr")
+	flag.BoolVar(&recClosed, "record-closed", false, "Record TCP ports that refused the connection (closed_ports in JSON)")
+	flag.Func("udp-probe", "Custom UDP probe port:hexpayload:hexmatch; the reply must contain the match bytes (repeatable)", func(s string) error {
+		probe, err := parseUDPProbe(s)
+		if err != nil {
+			return err
+		}
+		udpProbes = append(udpProbes, probe)
+		return nil
+	})
+	flag.BoolVar(&listIfs, "list-interfaces", false, "List network interfaces with MTU and link speed, then exit")
+	flag.StringVar(&labelsIn, "labels", "", "File mapping MAC addresses to friendly names, /etc/ethers format (default: /etc/ethers if present)")
+	flag.BoolVar(&diffOnly, "diff-only", false, "Output only new, gone, and changed hosts (implies -diff); JSON is grouped by change")
+	flag.StringVar(&methods, "method-order", "icmp,tcp,udp", "Probe methods in the order they are tried (omit one to disable it)")
+	flag.StringVar(&expectIn, "expected", "", "JSON allowlist of expected devices; flags UNKNOWN and MISSING hosts (default: ~/.localscan/expected.json if present)")
+	flag.StringVar(&udpList, "udp-ports", "", "Comma-separated UDP ports or ranges to probe, e.g. 53,123,5353 (default: built-in list)")
+	flag.BoolVar(&stream, "stream", false, "Write each host as soon as it is found instead of holding all results in memory (requires -format jsonl)")
+	flag.BoolVar(&appendHst, "append-history", false, "Append results to ~/.localscan/history.jsonl, one line per host")
+	flag.StringVar(&csvAppend, "csv-append", "", "Append this scan's rows, with a ScanTime column, to a CSV log file (header written only if the file is new or empty)")
+	flag.BoolVar(&showConf, "confidence", false, "Show a 0-100 confidence score per host in table and compact output (always in JSON)")
+	flag.StringVar(&portsBy, "ports-by", "number", "Order of open ports in table, compact and CSV output: number, risk (riskiest services such as Telnet, SMB and RDP first)")
+	flag.StringVar(&logFile, "log-file", "", "Append a structured audit log (JSON lines) of the scan to this file; -v adds debug records")
+	flag.StringVar(&diffIgnIn, "diff-ignore", "", "Roles left out of -diff statuses, comma-separated: self, gateway")
+	flag.BoolVar(&anonymize, "anonymize", false, "Redact results for sharing: mask the last 3 octets of MACs and replace hostnames and labels with hashes")
+	flag.BoolVar(&anonIPs, "anonymize-ips", false, "With -anonymize, also zero the host portion of IP addresses (implies -anonymize)")
+	flag.IntVar(&readLimit, "read-limit", scanner.DefaultReadLimit, "Bytes read from each UDP reply, banner and SNMP answer; raise it for devices whose replies get truncated")
+	flag.BoolVar(&increment, "incremental", false, "Append each host to jsonl and csv files given with -o as soon as it is found, then rewrite them with the full results at the end")
+	flag.BoolVar(&noDNS, "no-dns", false, "Skip reverse DNS and mDNS name lookups (hostnames show \"-\"), so the DNS server does not see the scan")
+	flag.DurationVar(&dnsTime, "dns-timeout", scanner.DefaultDNSTimeout, "Time limit for each host's reverse DNS lookup; the mDNS fallback gets half of it")
+	flag.IntVar(&vlan, "vlan", 0, "Scan VLAN N through its 802.1Q subinterface on the -interface trunk (Linux). The subinterface is only looked up: it must already exist, be up and have an address; localscan neither creates it nor tags frames itself")
+	flag.BoolVar(&resetHist, "reset-history", false, "Delete the stored scan history (last scan, history log, seen times, probe cache) and exit")
+	flag.BoolVar(&assumeYes, "yes", false, "With -reset-history, delete without asking for confirmation")
+	flag.BoolVar(&showVer, "version", false, "Print the localscan version and exit")
+	flag.BoolVar(&showRTT, "rtt", false, "Show each host's TCP round-trip time and latency bucket (wired <2ms, wireless 2-20ms, remote >20ms) in an RTT column")
+	flag.BoolVar(&passive, "passive", false, "Send no probes: list only hosts in the OS ARP cache (and, with -listen, hosts heard over mDNS/SSDP)")
+	flag.DurationVar(&listen, "listen", 0, "Listen this long for mDNS and SSDP announcements and merge what hosts announce into the results (e.g. 30s)")
+	flag.BoolVar(&discOnly, "discovery-only", false, "Only find which hosts are alive: stop probing TCP ports once a host answers and report no ports (faster)")
+	flag.BoolVar(&jsonMin, "json-minimal", false, "Leave empty fields out of json and jsonl output (unknown hostname, MAC and vendor, no open ports) instead of writing \"-\" or []")
+	flag.StringVar(&ouiFile, "oui-file", "", "IEEE MAC vendor registry to look vendors up in before the built-in table, in oui.csv or oui.txt format")
+	flag.StringVar(&portTime, "port-timeout", "", "Per-port TCP timeouts overriding -timeout, e.g. 443=2s,554=2s,631=1500ms")
+	flag.IntVar(&jitterPct, "timeout-jitter", 0, "Vary each probe's timeout at random by up to this percent either way (e.g. 10), so probes do not all time out at once")
+	flag.BoolVar(&twoPhase, "two-phase", false, "Ping every host first and send the TCP/UDP port probes only to hosts that answered or are in the ARP table (much faster on sparse networks)")
+	flag.DurationVar(&fullEvery, "full-probe-every", 0, "For repeated runs (e.g. from cron): fully probe a host at most this often, keeping its ports in ~/.localscan/probe-cache.json and only checking it is alive in between (e.g. 1h)")
+	flag.IntVar(&limit, "limit", 0, "Stop the scan once this many hosts are found and print them as a partial scan (0 for no limit)")
+	flag.BoolVar(&sumLine, "summary-line", false, "Print one status line to stderr when done (even with -quiet), e.g. \"localscan: 12 hosts, 3 new, 0 gone, 45s\"")
+	flag.StringVar(&snmpComms, "snmp-communities", "", "Comma-separated SNMP community strings to try on every found host, reporting which one each agent accepts (e.g. public,private)")
+	flag.StringVar(&snmpVer, "snmp-version", "1", "SNMP version for probes: 1, 2c")
+	flag.BoolVar(&banners, "banners", false, "Read the first line each open TCP port sends (connect mode), nudging HTTP, RTSP and SMTP first; shown in JSON as banners")
+	flag.BoolVar(&bcastPing, "broadcast-ping", false, "Ping the subnet broadcast address first; hosts that reply skip the per-host ping")
+	flag.StringVar(&sourceIP, "source", "", "Local IP address to send probes from (default: the -interface address if given, else chosen by the OS)")
+	flag.BoolVar(&selfTest, "self-test", false, "Scan mock hosts on loopback and report detection accuracy")
+	flag.Usage = usage
 	flag.Parse()
+	if showVer {
+		fmt.Println("localscan", scanner.Version)
+		return
+	}
+	if resetHist {
+		if !resetHistory(assumeYes) {
+			os.Exit(1)
+		}
+		return
+	}
+	if diffOnly {
+		diff = true
+	}
 
-	// Validate format
-	switch format {
-	case "table", "json", "csv":
-	default:
-		fmt.Fprintf(os.Stderr, "Error: unknown format %q (use table, json, or csv)\n", format)
+	if selfTest {
+		pass, err := scanner.SelfTest(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !pass {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if listIfs {
+		infos, err := scanner.ListInterfaces()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		selected := ""
+		if info, err := scanner.DetectInterface(ifaceName, virtual); err == nil {
+			selected = info.Name
+		}
+		display.PrintInterfaces(os.Stdout, infos, selected)
+		return
+	}
+
+	// Validate formats and where each one is written
+	formatList, err := parseFormats(format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	outPaths, err := outputPaths(formatList, output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if stream {
+		if len(formatList) != 1 || formatList[0] != "jsonl" {
+			fmt.Fprintf(os.Stderr, "Error: -stream requires -format jsonl\n")
+			os.Exit(1)
+		}
+		if passive || listen > 0 || twoPhase {
+			fmt.Fprintf(os.Stderr, "Error: -stream cannot be combined with -passive, -listen or -two-phase\n")
+			os.Exit(1)
+		}
+		if diff || portSum || expectIn != "" || syslogTo != "" || csvAppend != "" || reportErr {
+			fmt.Fprintf(os.Stderr, "Error: -stream cannot be combined with -diff, -port-summary, -expected, -syslog, -csv-append, or -report-errors\n")
+			os.Exit(1)
+		}
+	}
+	if readLimit < 1 || readLimit > scanner.MaxReadLimit {
+		fmt.Fprintf(os.Stderr, "Error: invalid -read-limit: %d is not between 1 and %d\n", readLimit, scanner.MaxReadLimit)
+		os.Exit(1)
+	}
+	if dnsTime <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid -dns-timeout: %v is not positive\n", dnsTime)
+		os.Exit(1)
+	}
+	// -vlan 0 is the default, so it is only an error when given explicitly
+	if vlan < 0 || vlan > 4094 || (vlan == 0 && flagGiven("vlan")) {
+		fmt.Fprintf(os.Stderr, "Error: invalid -vlan: %d is not between 1 and 4094\n", vlan)
+		os.Exit(1)
+	}
+	if discOnly && (banners || recClosed || minPorts > 0 || portSum) {
+		fmt.Fprintf(os.Stderr, "Error: -discovery-only collects no ports; it cannot be combined with -banners, -record-closed, -min-ports or -port-summary\n")
+		os.Exit(1)
+	}
+	if twoPhase && (discOnly || passive) {
+		fmt.Fprintf(os.Stderr, "Error: -two-phase cannot be combined with -discovery-only or -passive\n")
+		os.Exit(1)
+	}
+	if jitterPct < 0 || float64(jitterPct) > 100*scanner.MaxTimeoutJitter {
+		fmt.Fprintf(os.Stderr, "Error: invalid -timeout-jitter: %d is not between 0 and %.0f\n", jitterPct, 100*scanner.MaxTimeoutJitter)
+		os.Exit(1)
+	}
+	if fullEvery < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid -full-probe-every: %v is negative\n", fullEvery)
+		os.Exit(1)
+	}
+	if listen < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid -listen: %v is negative\n", listen)
+		os.Exit(1)
+	}
+	if limit < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid -limit: %d is negative\n", limit)
+		os.Exit(1)
+	}
+	display.SetQuiet(quiet)
+	display.SetShowConfidence(showConf)
+	display.SetShowRTT(showRTT)
+	display.SetJSONMinimal(jsonMin)
+	snmpVersion, err := scanner.ParseSNMPVersion(snmpVer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -snmp-version: %v\n", err)
+		os.Exit(1)
+	}
+	var snmpCommunities []string
+	for _, c := range strings.Split(snmpComms, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			snmpCommunities = append(snmpCommunities, c)
+		}
+	}
+	diffIgnore, err := scanner.ParseDiffIgnore(diffIgnIn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -diff-ignore: %v\n", err)
+		os.Exit(1)
+	}
+	display.SetDiffIgnore(diffIgnore)
+	if err := display.SetPortOrder(portsBy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -ports-by: %v\n", err)
+		os.Exit(1)
+	}
+
+	comma, err := parseDelimiter(csvDelim)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -csv-delim: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := scanner.ScanOptions{
+		Interface:       ifaceName,
+		IncludeVirtual:  virtual,
+		Workers:         workers,
+		MaxHosts:        limit,
+		Timeout:         time.Duration(timeout) * time.Millisecond,
+		ARPSettle:       time.Duration(arpSettle) * time.Millisecond,
+		TCPMode:         tcpMode,
+		IncludeSelf:     !noSelf,
+		HTTP:            httpProbe,
+		MDNSServices:    mdnsSvcs,
+		RouterHostnames: routerDNS,
+		RecordClosed:    recClosed,
+		UDPProbes:       udpProbes,
+		BroadcastPing:   bcastPing,
+		Banners:         banners,
+		DiscoveryOnly:   discOnly,
+		TwoPhase:        twoPhase,
+		TimeoutJitter:   float64(jitterPct) / 100,
+		ReadLimit:       readLimit,
+		SkipDNS:         noDNS,
+		DNSTimeout:      dnsTime,
+		VLAN:            vlan,
+		Passive:         passive,
+		Listen:          listen,
+		SNMPVersion:     snmpVersion,
+		SNMPCommunities: snmpCommunities,
+	}
+
+	if err := scanner.DataDirErr(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; keeping state in %s\n", err, scanner.DataDir())
+	}
+
+	if fullEvery > 0 {
+		if opts.Cache, err = scanner.LoadProbeCache(fullEvery); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot load probe cache, probing every host fully: %v\n", err)
+			opts.Cache = scanner.NewProbeCache(fullEvery)
+		}
+	}
+
+	// Load the OUI registry first so that config vendor prefixes override it
+	if ouiFile != "" {
+		if opts.Vendors, err = scanner.LoadOUIFile(ouiFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot load OUI file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Load config file
+	cfg, err := loadConfig(cfgOrDefault(cfgPath), cfgPath != "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.apply(&opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Detect network interface
-	info, err := scanner.DetectInterface(ifaceName)
+	if sourceIP != "" {
+		if opts.SourceIP = net.ParseIP(sourceIP).To4(); opts.SourceIP == nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -source: %q is not an IPv4 address\n", sourceIP)
+			os.Exit(1)
+		}
+	}
+
+	if opts.UDPPorts, err = parsePortList(udpList); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -udp-ports: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.PortTimeouts, err = parsePortTimeouts(portTime); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -port-timeout: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.MethodOrder, err = scanner.ParseMethodOrder(methods); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -method-order: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Load device labels; /etc/ethers is optional unless given explicitly
+	if labelsIn != "" {
+		if opts.Labels, err = scanner.LoadLabels(labelsIn); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot load labels: %v\n", err)
+			os.Exit(1)
+		}
+	} else if labels, err := scanner.LoadLabels(scanner.EthersPath); err == nil {
+		opts.Labels = labels
+	}
+
+	// Load the expected-devices allowlist; the default file is optional
+	var expected []scanner.ExpectedDevice
+	if expectIn != "" {
+		if expected, err = scanner.LoadExpected(expectIn); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot load expected devices: %v\n", err)
+			os.Exit(1)
+		}
+	} else if _, err := os.Stat(scanner.ExpectedPath()); err == nil && !stream {
+		if expected, err = scanner.LoadExpected(scanner.ExpectedPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot load expected devices: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	checkExpected := expected != nil
+
+	if err := scanner.CheckTCPMode(tcpMode); err != nil {
+		if tcpMode != scanner.TCPSYN {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v; falling back to connect scan\n", err)
+		opts.TCPMode = scanner.TCPConnect
+	}
+
+	// Collect explicit targets; the interface networks are used if none
+	opts.Targets, err = targetSpecs(target, targetsIn)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Calculate hosts to scan
-	hosts := scanner.HostsInNetwork(info.Network)
-	if len(hosts) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: no hosts in network %s\n", info.CIDR())
+	// The audit log is separate from the progress output and the results
+	logger, logCloser, err := openScanLog(logFile, verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot open log file: %v\n", err)
 		os.Exit(1)
 	}
+	defer logCloser.Close()
+
+	// Detect network interface and calculate hosts to scan
+	plan, err := scanner.NewPlan(opts)
+	if err != nil {
+		logger.Error("scan setup failed", "err", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if plan.DetectErr != nil {
+		logger.Warn("interface detection failed", "err", plan.DetectErr)
+		if plan.Interface != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; scanning targets from %s\n", plan.DetectErr, plan.Interface.IP)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: %v; scanning targets anyway\n", plan.DetectErr)
+		}
+	}
+
+	cidr := plan.Label
+	total := plan.Count()
 
-	cidr := info.CIDR()
-	total := len(hosts)
+	// Results are anonymized only on the way out; history, logs and syslog
+	// keep the real values
+	var anon *anonymizer
+	if anonymize || anonIPs {
+		anon = newAnonymizer(plan, anonIPs)
+	}
+
+	if dryRun {
+		for _, ip := range plan.Hosts() {
+			if name := plan.TargetName(ip); name != "" {
+				fmt.Printf("%s\t%s\n", ip, name)
+			} else {
+				fmt.Println(ip)
+			}
+		}
+		fmt.Printf("%d hosts would be scanned in %s\n", total, cidr)
+		return
+	}
 
 	display.PrintHeader(cidr, total)
+	if opts.Passive {
+		fmt.Fprintf(os.Stderr, "Note: passive mode sends no probes; only hosts that recently exchanged traffic with this machine or announce themselves are listed\n")
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Using %d workers\n", plan.Workers())
+	}
 
 	// Start scan
 	start := time.Now()
-	progressCh := make(chan scanner.Progress, workers)
+	logger.Info("scan started", planAttrs(plan)...)
+	progressCh := make(chan scanner.Progress, plan.Workers())
 
-	var results []scanner.ScanResult
+	var histLog *scanner.HistoryLog
+	if appendHst {
+		if histLog, err = scanner.OpenHistoryLog(start); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot open history log: %v\n", err)
+			os.Exit(1)
+		}
+		defer histLog.Close()
+	}
+
+	// -incremental opens the jsonl and csv output files before the scan so
+	// hosts can be appended as they are found; -stream already does this
+	partial := make(map[int]*partialFile)
+	if increment && !stream {
+		for i, format := range formatList {
+			if outPaths[i] == "" || isNetOutput(outPaths[i]) || (format != "jsonl" && format != "csv") {
+				continue
+			}
+			f, err := createOutputFile(expandOutputPath(outPaths[i], start, cidr, format))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: cannot create output file: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			partial[i] = &partialFile{File: f, format: format}
+		}
+		if len(partial) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: -incremental only applies to jsonl and csv output written to a file with -o\n")
+		}
+	}
+	csvOpts := display.CSVOptions{Comma: comma, NoHeader: csvNoHead}
+
+	// In stream mode each host is written as soon as it is enriched
+	var emit func(scanner.ScanResult) error
+	if stream {
+		var w io.Writer = os.Stdout
+		if outPaths[0] != "" {
+			f, err := openOutput(expandOutputPath(outPaths[0], start, cidr, "jsonl"))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: cannot open output: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		emit = func(r scanner.ScanResult) error {
+			if histLog != nil {
+				if err := histLog.Append(r); err != nil {
+					return fmt.Errorf("append history: %w", err)
+				}
+			}
+			if len(r.OpenPorts) < minPorts {
+				return nil
+			}
+			if anon != nil {
+				r = anon.result(r)
+			}
+			return display.WriteJSONL(w, r)
+		}
+	}
+
+	// Diff mode compares with the previous scan. It is loaded before the
+	// scan so syslog can flag NEW and CHANGED hosts as they are found.
+	var previous, compared []scanner.ScanResult
+	if diff {
+		var err error
+		previous, err = scanner.LoadHistory()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Note: no previous scan data found, all hosts marked as NEW\n")
+		}
+		compared = previous
+		if passive || discOnly {
+			// Ports were not collected, so none can have opened or closed
+			compared = withoutPorts(previous)
+		}
+	}
+
+	// Forward each host to syslog as soon as it is found
+	var (
+		sl     *notify.Syslog
+		slSent map[string]bool
+		slGW   net.IP
+	)
+	if syslogTo != "" {
+		var err error
+		if sl, err = notify.DialSyslog(syslogTo); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot connect to syslog: %v\n", err)
+		} else {
+			defer sl.Close()
+			slSent = make(map[string]bool)
+			if slices.Contains(diffIgnore, "gateway") {
+				slGW, _ = scanner.DefaultGateway()
+			}
+		}
+	}
+	sendSyslog := func(r scanner.ScanResult) {
+		if err := sl.Send(r); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: syslog send failed: %v\n", err)
+			sl.Close()
+			sl = nil
+		}
+	}
+
+	var (
+		report *scanner.Report
+		runErr error
+	)
 	done := make(chan struct{})
 
-	// Run scan in background goroutine
+	// Ctrl-C stops the scan and prints what was found so far; after that, a
+	// second Ctrl-C kills the process as usual.
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-sigCtx.Done()
+		stop()
+	}()
+	ctx := sigCtx
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+	// Run scan and enrichment in background goroutine
 	go func() {
-		results = scanner.Scan(hosts, workers, time.Duration(timeout)*time.Millisecond, progressCh)
+		if stream {
+			report, runErr = plan.Stream(ctx, progressCh, emit)
+		} else {
+			report, runErr = plan.Run(ctx, progressCh)
+		}
 		close(progressCh)
 		close(done)
 	}()
 
 	// Display progress from channel until closed
 	maxProgress := 0
+	foundBy := make(map[string]int)
 	for p := range progressCh {
 		if p.Current > maxProgress {
 			maxProgress = p.Current
 		}
 		if p.Found != nil {
-			display.PrintFound(p.Found)
+			if anon != nil {
+				r := anon.result(*p.Found)
+				display.PrintFound(&r)
+			} else {
+				display.PrintFound(p.Found)
+			}
+			foundBy[p.Found.Method]++
+			if len(p.Found.OpenPorts) >= minPorts {
+				r := *p.Found
+				if anon != nil {
+					r = anon.result(r)
+				}
+				for i, pf := range partial {
+					if err := pf.add(r, csvOpts); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: cannot write %s output: %v\n", pf.format, err)
+						delete(partial, i)
+					}
+				}
+			}
+			if sl != nil {
+				r := *p.Found
+				if diff {
+					r.Status, r.PortChanges = liveDiff(r, compared, diffIgnore, slGW)
+				}
+				slSent[r.IP.String()] = true
+				sendSyslog(r)
+			}
+			logger.Debug("host found", "ip", p.Found.IP.String(), "method", p.Found.Method, "open_ports", p.Found.OpenPorts)
 		}
 		display.PrintProgress(maxProgress, total, p.IP)
 	}
@@ -87,80 +661,409 @@ func main() {
 	<-done
 
 	display.PrintComplete(total)
-
-	// Enrich all results with hostname, MAC, vendor
-	arpTable := scanner.GetARPTable()
-	for i := range results {
-		ipStr := results[i].IP.String()
-		results[i].Hostname = scanner.ResolveHostname(ipStr)
-		if mac, ok := arpTable[ipStr]; ok {
-			results[i].MAC = mac
-			results[i].Vendor = scanner.LookupVendor(mac)
+	if report != nil {
+		display.PrintPhases(report.Phases)
+		for _, ph := range report.Phases {
+			logger.Info("scan phase", "phase", ph.Name, "hosts", ph.Hosts, "found", ph.Found, "elapsed_ms", ph.Elapsed.Milliseconds())
+		}
+	}
+	if runErr != nil {
+		if sumLine {
+			fmt.Fprintf(os.Stderr, "localscan: failed: %v\n", runErr)
+		}
+		logger.Error("scan failed", "err", runErr, "elapsed_ms", time.Since(start).Milliseconds())
+		fmt.Fprintf(os.Stderr, "Error: %v\n", runErr)
+		os.Exit(1)
+	}
+	if opts.Cache != nil {
+		if err := opts.Cache.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save probe cache: %v\n", err)
+		}
+	}
+	coverage := display.NewCoverage(report)
+	if !coverage.Complete {
+		logger.Warn("scan incomplete", "reason", coverage.Reason, "scanned", coverage.Scanned, "total", coverage.Total)
+		if report.Complete() {
+			fmt.Fprintf(os.Stderr, "Warning: scan incomplete (%s): every host was probed, but lookups such as host names were cut short\n", coverage.Reason)
 		} else {
-			results[i].MAC = "-"
-			results[i].Vendor = "-"
+			fmt.Fprintf(os.Stderr, "Warning: scan incomplete (%s): %s, %d hosts were not scanned\n",
+				coverage.Reason, coverage, report.Total-report.Scanned)
 		}
 	}
-
-	// Sort results by IP
-	sort.Slice(results, func(i, j int) bool {
-		return ipToUint32(results[i].IP) < ipToUint32(results[j].IP)
-	})
+	for _, w := range report.Warnings {
+		logger.Warn(w)
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	for _, e := range report.Errors {
+		logger.Debug("probe error", "ip", e.IP.String(), "err", e.Err)
+	}
+	logger.Info("scan finished",
+		"scanned", report.Scanned,
+		"total", report.Total,
+		"coverage_pct", coverage.Percent,
+		"results", len(report.Results),
+		"probe_errors", len(report.Errors),
+		"packets_sent", report.Stats.Packets,
+		"bytes_sent", report.Stats.Bytes,
+		"elapsed_ms", time.Since(start).Milliseconds(),
+		methodCounts(foundBy))
+	if stream {
+		if sumLine {
+			fmt.Fprintln(os.Stderr, summaryLine(countFound(foundBy), nil, coverage, time.Since(start)))
+		}
+		return
+	}
+	results := report.Results
 
 	// Diff mode: compare with previous scan
 	if diff {
-		previous, err := scanner.LoadHistory()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Note: no previous scan data found, all hosts marked as NEW\n")
-		}
-		results = scanner.ComputeDiff(results, previous)
+		results = scanner.ComputeDiffIgnoring(results, compared, diffIgnore)
 		// Re-sort after adding GONE entries
-		sort.Slice(results, func(i, j int) bool {
-			return ipToUint32(results[i].IP) < ipToUint32(results[j].IP)
-		})
+		scanner.SortResults(results)
 	}
 
-	// Save current results for future diff (only non-GONE entries)
-	if diff {
+	// Track when each device was first and last seen, with or without -diff
+	if seen, err := scanner.LoadSeen(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot read seen times: %v\n", err)
+	} else {
+		scanner.JoinSeen(results, seen, start)
+		if err := seen.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save seen times: %v\n", err)
+		}
+	}
+
+	// Flag hosts that are not on the allowlist and expected ones not found
+	if checkExpected {
+		results = scanner.CheckExpected(results, expected)
+		scanner.SortResults(results)
+	}
+
+	// Hosts found by the scan went to syslog as they were found. What is
+	// left is known only now: hosts added after the probes (this host,
+	// listened and passive ones), GONE and MISSING hosts, and UNKNOWN hosts,
+	// which need the enriched MAC to be matched against the allowlist.
+	for _, r := range results {
+		if sl == nil {
+			break
+		}
+		switch {
+		case r.Status == "GONE" || r.Status == "MISSING" || r.Unexpected:
+		case r.IP != nil && !slSent[r.IP.String()]:
+		default:
+			continue
+		}
+		sendSyslog(r)
+	}
+
+	// Save current results for future diff (only hosts that answered). A
+	// partial scan would make the unscanned hosts look GONE next time.
+	if diff && !coverage.Complete {
+		fmt.Fprintf(os.Stderr, "Note: scan history not updated because the scan is partial\n")
+	} else if diff {
 		var toSave []scanner.ScanResult
 		for _, r := range results {
-			if r.Status != "GONE" {
+			if r.Status != "GONE" && r.Status != "MISSING" {
 				toSave = append(toSave, r)
 			}
 		}
+		if passive || discOnly {
+			keepPorts(toSave, previous)
+		}
 		if err := scanner.SaveHistory(toSave); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to save scan history: %v\n", err)
 		}
 	}
+	if histLog != nil {
+		for _, r := range results {
+			if r.Status == "GONE" || r.Status == "MISSING" {
+				continue
+			}
+			if err := histLog.Append(r); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to append history: %v\n", err)
+				break
+			}
+		}
+	}
 
-	// Determine output writer
-	var w io.Writer = os.Stdout
-	if output != "" {
-		f, err := os.Create(output)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: cannot create output file: %v\n", err)
-			os.Exit(1)
+	// Append to the CSV log before filtering, like the history
+	if csvAppend != "" {
+		if err := appendCSVLog(csvAppend, results, start, comma); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to append CSV log: %v\n", err)
 		}
-		defer f.Close()
-		w = f
+	}
+
+	var summary *summaryCounts
+	if diff || checkExpected {
+		summary = &summaryCounts{diff: diff, expected: checkExpected, results: results}
+	}
+	hostCount := countPresent(results)
+
+	// Hide hosts without enough open ports; history keeps everything
+	if minPorts > 0 {
+		results = filterMinPorts(results, minPorts)
 	}
 
 	elapsed := time.Since(start).Round(100 * time.Millisecond).String()
+	meta := plan.Meta()
+	hostErrs, checks := report.Errors, report.Checks
+	if anon != nil {
+		results = anon.results(results)
+		hostErrs = anon.errors(hostErrs)
+		checks = anon.checks(checks)
+		meta = anon.meta(meta)
+	}
+
+	// Write each requested format to its own destination
+	for i, format := range formatList {
+		var w io.Writer = os.Stdout
+		if pf := partial[i]; pf != nil {
+			if err := pf.reset(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: cannot rewrite output file: %v\n", err)
+				os.Exit(1)
+			}
+			w = pf
+		} else if outPaths[i] != "" {
+			f, err := openOutput(expandOutputPath(outPaths[i], start, cidr, format))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: cannot open output: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		writeFormat(w, format, results, elapsed, outputOptions{
+			portSum:   portSum,
+			diff:      diff,
+			errors:    hostErrs,
+			reportErr: reportErr,
+			diffOnly:  diffOnly,
+			expected:  checkExpected,
+			meta:      &meta,
+			coverage:  &coverage,
+			stats:     &report.Stats,
+			util:      display.NewUtilization(hostCount, report.Total),
+			checks:    checks,
+			csv:       csvOpts,
+		})
+	}
+	if sumLine {
+		fmt.Fprintln(os.Stderr, summaryLine(hostCount, summary, coverage, time.Since(start)))
+	}
+}
 
+// flagGiven reports whether the named flag was set on the command line.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// hiddenFlags are development flags left out of -help.
+var hiddenFlags = map[string]bool{"self-test": true}
+
+// usage prints the flag defaults like the flag package does, without
+// hiddenFlags.
+func usage() {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			fs.Var(f.Value, f.Name, f.Usage)
+			fs.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	fmt.Fprintf(fs.Output(), "Usage of %s:\n", os.Args[0])
+	fs.PrintDefaults()
+}
+
+// outputOptions carries the flags that shape the result output.
+type outputOptions struct {
+	portSum   bool
+	diff      bool
+	errors    []scanner.HostError
+	reportErr bool
+	diffOnly  bool
+	expected  bool
+	meta      *scanner.ScanMeta
+	coverage  *display.Coverage
+	stats     *scanner.ProbeStats
+	util      display.Utilization
+	checks    []scanner.PortCheck
+	csv       display.CSVOptions
+}
+
+// writeFormat writes results to w in the given format.
+func writeFormat(w io.Writer, format string, results []scanner.ScanResult, elapsed string, o outputOptions) {
+	portSum, diff := o.portSum, o.diff
+	all := results // the diff summary still counts unchanged hosts
+	if o.diffOnly {
+		if format == "json" {
+			display.PrintDiffJSON(w, results, o.meta, o.coverage)
+			return
+		}
+		results = changedOnly(results)
+	}
 	switch format {
 	case "json":
-		display.PrintResultsJSON(w, results, elapsed)
+		jsonOpts := display.JSONOptions{PortSummary: portSum, DiffSummary: diff, Expected: o.expected, Meta: o.meta, Coverage: o.coverage, Stats: o.stats, Utilization: &o.util, Checks: o.checks}
+		if o.reportErr {
+			jsonOpts.Errors = o.errors
+		}
+		display.PrintResultsJSON(w, results, elapsed, jsonOpts)
+	case "jsonl":
+		display.PrintResultsJSONL(w, results)
 	case "csv":
-		display.PrintResultsCSV(w, results, elapsed)
+		display.PrintResultsCSV(w, results, elapsed, o.csv)
+	case "compact":
+		display.PrintResultsCompact(w, results, elapsed)
+		display.PrintProbeStats(w, *o.stats)
+		display.PrintUtilization(w, o.util.InUse, o.util.Total)
+		display.PrintCoverage(w, *o.coverage)
+		if diff {
+			display.PrintDiffSummary(w, all)
+		}
+		if o.expected {
+			display.PrintExpectedSummary(w, all)
+		}
+		display.PrintSNMPFindings(w, results)
+		display.PrintChecks(w, o.checks)
+	case "ips":
+		display.PrintResultsIPs(w, results)
+	case "ssh-config":
+		display.PrintResultsSSHConfig(w, results)
+	case "ip:port":
+		display.PrintResultsIPPorts(w, results)
 	default:
 		display.PrintResults(w, results, elapsed)
+		display.PrintProbeStats(w, *o.stats)
+		display.PrintUtilization(w, o.util.InUse, o.util.Total)
+		display.PrintCoverage(w, *o.coverage)
+		if diff {
+			display.PrintDiffSummary(w, all)
+		}
+		if o.expected {
+			fmt.Fprintln(w)
+			display.PrintExpectedSummary(w, all)
+		}
+		if portSum {
+			fmt.Fprintln(w)
+			display.PrintPortSummary(w, results)
+		}
+		display.PrintSNMPFindings(w, results)
+		if len(o.checks) > 0 {
+			fmt.Fprintln(w)
+			display.PrintChecks(w, o.checks)
+		}
+	}
+}
+
+// changedOnly returns the results that have a diff status or are not on
+// the expected-devices list.
+func changedOnly(results []scanner.ScanResult) []scanner.ScanResult {
+	var kept []scanner.ScanResult
+	for _, r := range results {
+		if r.Status != "" || r.Unexpected {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// filterMinPorts returns the results with at least n open TCP ports.
+func filterMinPorts(results []scanner.ScanResult, n int) []scanner.ScanResult {
+	var kept []scanner.ScanResult
+	for _, r := range results {
+		if len(r.OpenPorts) >= n {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// resetHistory deletes the stored history files after listing them and,
+// unless yes is set, asking for confirmation on stdin. It reports whether
+// nothing went wrong; declining is not an error.
+func resetHistory(yes bool) bool {
+	files := scanner.HistoryFiles()
+	if len(files) == 0 {
+		fmt.Printf("No scan history in %s\n", scanner.DataDir())
+		return true
+	}
+	if !yes {
+		fmt.Println("This deletes:")
+		for _, p := range files {
+			fmt.Printf("  %s\n", p)
+		}
+		fmt.Print("Continue? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Nothing deleted")
+			return true
+		}
 	}
+	ok := true
+	for _, p := range files {
+		if err := os.Remove(p); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			ok = false
+			continue
+		}
+		fmt.Printf("Removed %s\n", p)
+	}
+	return ok
+}
+
+// liveDiff returns the -diff status of a host that was just found, before
+// enrichment, so it can be reported right away. Roles are not known yet, so
+// the gateway is recognized by its address gw.
+func liveDiff(r scanner.ScanResult, previous []scanner.ScanResult, ignore []string, gw net.IP) (string, *scanner.PortDiff) {
+	if gw != nil && r.IP.Equal(gw) {
+		r.Role = "gateway"
+	}
+	d := scanner.ComputeDiffIgnoring([]scanner.ScanResult{r}, previous, ignore)[0]
+	return d.Status, d.PortChanges
 }
 
-func ipToUint32(ip net.IP) uint32 {
-	ip = ip.To4()
-	if ip == nil {
-		return 0
+// withoutPorts returns a copy of results without their open ports.
+func withoutPorts(results []scanner.ScanResult) []scanner.ScanResult {
+	stripped := slices.Clone(results)
+	for i := range stripped {
+		stripped[i].OpenPorts = nil
+	}
+	return stripped
+}
+
+// keepPorts gives results the open ports their hosts had in previous, for
+// scans that collected no ports, so the next full scan is compared with
+// the last known ports rather than none.
+func keepPorts(results, previous []scanner.ScanResult) {
+	ports := make(map[string][]int, len(previous))
+	for _, r := range previous {
+		ports[r.IP.String()] = r.OpenPorts
+	}
+	for i := range results {
+		results[i].OpenPorts = ports[results[i].IP.String()]
+	}
+}
+
+// targetSpecs collects target specs from the -target flag and -targets-file.
+func targetSpecs(target, file string) ([]string, error) {
+	var specs []string
+	for _, spec := range strings.Split(target, ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	if file != "" {
+		fromFile, err := scanner.ReadTargetsFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read targets file: %w", err)
+		}
+		specs = append(specs, fromFile...)
 	}
-	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	return specs, nil
 }
@@ -0,0 +1,143 @@
+// Package notify forwards discovered hosts to external collectors.
+package notify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"localscan/scanner"
+)
+
+// Syslog facility and severities (RFC 5424 section 6.2.1).
+const (
+	facilityUser     = 1
+	severityWarning  = 4
+	severityNotice   = 5
+	severityInfo     = 6
+	syslogAppName    = "localscan"
+	syslogSDID       = "host@32473" // example private enterprise number
+	defaultSyslogNet = "udp"
+)
+
+// Syslog sends one RFC 5424 message per host to a syslog collector.
+type Syslog struct {
+	conn     net.Conn
+	network  string
+	hostname string
+}
+
+// DialSyslog connects to a syslog collector. addr is "host:port",
+// optionally prefixed with "udp://" or "tcp://" (UDP by default).
+func DialSyslog(addr string) (*Syslog, error) {
+	network := defaultSyslogNet
+	if scheme, rest, ok := strings.Cut(addr, "://"); ok {
+		network, addr = scheme, rest
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog transport %q (use udp or tcp)", network)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "514")
+	}
+
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &Syslog{conn: conn, network: network, hostname: hostname}, nil
+}
+
+// Send emits a message describing r. NEW and unexpected hosts are logged at
+// warning severity, GONE and MISSING hosts at notice, and everything else
+// at info.
+func (s *Syslog) Send(r scanner.ScanResult) error {
+	msg := s.format(r, time.Now())
+	if s.network == "tcp" {
+		// Octet-counting framing (RFC 6587 section 3.4.1).
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+// Close closes the connection to the collector.
+func (s *Syslog) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Syslog) format(r scanner.ScanResult, now time.Time) string {
+	severity := severityInfo
+	switch r.Status {
+	case "NEW":
+		severity = severityWarning
+	case "GONE", "MISSING":
+		severity = severityNotice
+	case "CHANGED":
+		severity = severityNotice
+		if r.PortChanges != nil && len(r.PortChanges.Opened) > 0 {
+			severity = severityWarning // a newly opened port is worth a look
+		}
+	}
+	if r.Unexpected {
+		severity = severityWarning
+	}
+
+	ip := "-" // a missing expected device may have no known address
+	if r.IP != nil {
+		ip = r.IP.String()
+	}
+
+	ports := make([]string, len(r.OpenPorts))
+	for i, p := range r.OpenPorts {
+		ports[i] = strconv.Itoa(p)
+	}
+
+	sd := fmt.Sprintf(`[%s ip="%s" mac="%s" vendor="%s" method="%s" ports="%s"`,
+		syslogSDID,
+		sdEscape(ip),
+		sdEscape(r.MAC),
+		sdEscape(r.Vendor),
+		sdEscape(r.Method),
+		strings.Join(ports, ","),
+	)
+	if r.Status != "" {
+		sd += fmt.Sprintf(` status="%s"`, sdEscape(r.Status))
+	}
+	if r.Unexpected {
+		sd += ` unexpected="true"`
+	}
+	sd += "]"
+
+	text := fmt.Sprintf("host %s", ip)
+	if r.Status != "" {
+		text += " " + r.Status
+	}
+	if r.Hostname != "" && r.Hostname != "-" {
+		text += " (" + r.Hostname + ")"
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		facilityUser*8+severity,
+		now.Format(time.RFC3339),
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		"host",
+		sd,
+		text,
+	)
+}
+
+// sdEscape escapes a structured-data parameter value (RFC 5424 section 6.3.3).
+func sdEscape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
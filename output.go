@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"localscan/display"
+	"localscan/scanner"
+)
+
+// formats lists the accepted -format values.
+var formats = []string{"table", "compact", "json", "jsonl", "csv", "ips", "ip:port", "ssh-config"}
+
+// formatFiles names the file each format is written to when -o is a
+// directory.
+var formatFiles = map[string]string{
+	"table":      "scan.txt",
+	"compact":    "scan-compact.txt",
+	"json":       "scan.json",
+	"jsonl":      "scan.jsonl",
+	"csv":        "scan.csv",
+	"ips":        "scan-ips.txt",
+	"ip:port":    "scan-ip-port.txt",
+	"ssh-config": "ssh_config",
+}
+
+// parseFormats splits a comma-separated -format value and validates each
+// entry. Duplicates are dropped.
+func parseFormats(s string) ([]string, error) {
+	var list []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(formats, f) {
+			return nil, fmt.Errorf("unknown format %q (use %s)", f, strings.Join(formats, ", "))
+		}
+		if !slices.Contains(list, f) {
+			list = append(list, f)
+		}
+	}
+	return list, nil
+}
+
+// outputPaths assigns an -o value to each requested format; "" means
+// stdout. With several formats, -o must be a path template containing
+// {format}, a directory, or a comma-separated list with one path per
+// format. The list may leave out one path, in which case the table (or
+// compact) output goes to stdout.
+func outputPaths(list []string, output string) ([]string, error) {
+	paths := make([]string, len(list))
+	if len(list) == 1 || output == "" {
+		if len(list) > 1 {
+			return nil, fmt.Errorf("-o is required with several formats")
+		}
+		paths[0] = output
+		return paths, nil
+	}
+
+	if strings.Contains(output, "{format}") {
+		for i := range list {
+			paths[i] = output
+		}
+		return paths, nil
+	}
+	if isDirPath(output) {
+		for i, f := range list {
+			paths[i] = filepath.Join(output, formatFiles[f])
+		}
+		return paths, nil
+	}
+
+	given := strings.Split(output, ",")
+	switch len(given) {
+	case len(list):
+		copy(paths, given)
+		return paths, nil
+	case len(list) - 1:
+		human := slices.IndexFunc(list, func(f string) bool { return f == "table" || f == "compact" })
+		if human < 0 {
+			break
+		}
+		for i, j := 0, 0; i < len(list); i++ {
+			if i != human {
+				paths[i] = given[j]
+				j++
+			}
+		}
+		return paths, nil
+	}
+	return nil, fmt.Errorf("-o needs one path per format (%d), a directory, or {format}", len(list))
+}
+
+// isDirPath reports whether path names a directory: it ends with a path
+// separator or already exists as one.
+func isDirPath(path string) bool {
+	if strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator)) {
+		return true
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// expandOutputPath replaces template tokens in an -o path:
+//
+//	{date}     2006-01-02
+//	{time}     150405
+//	{datetime} 20060102-150405
+//	{cidr}     scanned range with "/" and "," made filename-safe
+//	{format}   output format
+func expandOutputPath(tmpl string, now time.Time, cidr, format string) string {
+	safeCIDR := strings.NewReplacer("/", "_", ",", "+", ":", "-").Replace(cidr)
+	safeFormat := strings.ReplaceAll(format, ":", "-")
+	return strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{datetime}", now.Format("20060102-150405"),
+		"{cidr}", safeCIDR,
+		"{format}", safeFormat,
+	).Replace(tmpl)
+}
+
+// createOutputFile creates path, making its parent directory if needed.
+func createOutputFile(path string) (*os.File, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return os.Create(path)
+}
+
+// netDialTimeout bounds connecting to a tcp:// or unix:// -o endpoint.
+const netDialTimeout = 5 * time.Second
+
+// isNetOutput reports whether an -o path names a network endpoint,
+// tcp://host:port or unix:///path.sock, rather than a file.
+func isNetOutput(path string) bool {
+	return strings.HasPrefix(path, "tcp://") || strings.HasPrefix(path, "unix://")
+}
+
+// openOutput opens an -o destination for writing: it connects to a
+// network endpoint, or creates the file.
+func openOutput(path string) (io.WriteCloser, error) {
+	if isNetOutput(path) {
+		return dialOutput(path)
+	}
+	return createOutputFile(path)
+}
+
+// netOutput writes output to a TCP or Unix socket endpoint, such as a
+// collector feeding a dashboard. If a write fails because the collector
+// went away, it reconnects once and writes again, so a long -stream scan
+// survives a collector restart.
+type netOutput struct {
+	network, addr string
+	conn          net.Conn
+}
+
+// dialOutput connects to a tcp://host:port or unix:///path.sock endpoint.
+func dialOutput(dest string) (*netOutput, error) {
+	network, addr, _ := strings.Cut(dest, "://")
+	if network == "tcp" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("%s: want tcp://host:port", dest)
+		}
+	}
+	o := &netOutput{network: network, addr: addr}
+	if err := o.dial(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *netOutput) dial() error {
+	conn, err := net.DialTimeout(o.network, o.addr, netDialTimeout)
+	if err != nil {
+		return err
+	}
+	o.conn = conn
+	return nil
+}
+
+// Write sends p, reconnecting once if the connection has failed. After a
+// partial write, only the rest of p is sent on the new connection.
+func (o *netOutput) Write(p []byte) (int, error) {
+	written := 0
+	if o.conn != nil {
+		n, err := o.conn.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		written = n
+		o.conn.Close()
+		o.conn = nil
+	}
+	if err := o.dial(); err != nil {
+		return written, fmt.Errorf("reconnect to %s://%s: %w", o.network, o.addr, err)
+	}
+	n, err := o.conn.Write(p[written:])
+	return written + n, err
+}
+
+// Close closes the connection.
+func (o *netOutput) Close() error {
+	if o.conn == nil {
+		return nil
+	}
+	return o.conn.Close()
+}
+
+// partialFile is a jsonl or csv output file that hosts are appended to as
+// they are found (-incremental), so an interrupted scan leaves what it had
+// found on disk. Those hosts are not enriched yet; the file is rewritten
+// with the final results when the scan ends.
+type partialFile struct {
+	*os.File
+	format string
+	hosts  int
+}
+
+// add appends r in the file's format. The CSV header is written with the
+// first host.
+func (pf *partialFile) add(r scanner.ScanResult, opts display.CSVOptions) error {
+	switch pf.format {
+	case "jsonl":
+		if err := display.WriteJSONL(pf, r); err != nil {
+			return err
+		}
+	case "csv":
+		opts.NoHeader = opts.NoHeader || pf.hosts > 0
+		display.PrintResultsCSV(pf, []scanner.ScanResult{r}, "", opts)
+	}
+	pf.hosts++
+	return nil
+}
+
+// reset empties the file for the final results.
+func (pf *partialFile) reset() error {
+	if err := pf.Truncate(0); err != nil {
+		return err
+	}
+	_, err := pf.Seek(0, io.SeekStart)
+	return err
+}
+
+// appendCSVLog appends results as CSV rows stamped with the scan time to
+// the file at path, writing the header only if the file is new or empty.
+func appendCSVLog(path string, results []scanner.ScanResult, scanTime time.Time, comma rune) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	display.PrintResultsCSV(f, results, "", display.CSVOptions{
+		Comma:    comma,
+		NoHeader: info.Size() > 0,
+		ScanTime: scanTime,
+	})
+	return f.Close()
+}
+
+// parseDelimiter parses a -csv-delim value into a single rune. The escape
+// "\t" and the word "tab" are accepted for a tab character.
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) {
+		return 0, fmt.Errorf("%q is not a single character", s)
+	}
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("%q cannot be used as a delimiter", s)
+	}
+	return r, nil
+}
+
+// summaryCounts selects the status counts shown by summaryLine.
+type summaryCounts struct {
+	diff     bool
+	expected bool
+	results  []scanner.ScanResult
+}
+
+// summaryLine formats the -summary-line status, e.g.
+// "localscan: 12 hosts, 3 new, 0 gone, 45s", noting partial scans.
+func summaryLine(hosts int, counts *summaryCounts, coverage display.Coverage, elapsed time.Duration) string {
+	parts := []string{fmt.Sprintf("%d hosts", hosts)}
+	if counts != nil && counts.diff {
+		d := display.CountDiff(counts.results)
+		parts = append(parts, fmt.Sprintf("%d new", d.New), fmt.Sprintf("%d gone", d.Gone))
+	}
+	if counts != nil && counts.expected {
+		e := display.CountExpected(counts.results)
+		parts = append(parts, fmt.Sprintf("%d unknown", e.Unknown), fmt.Sprintf("%d missing", e.Missing))
+	}
+	if !coverage.Complete {
+		parts = append(parts, fmt.Sprintf("partial %.1f%% (%s)", coverage.Percent, coverage.Reason))
+	}
+	parts = append(parts, elapsed.Round(100*time.Millisecond).String())
+	return "localscan: " + strings.Join(parts, ", ")
+}
+
+// countPresent returns the number of results for hosts that answered,
+// leaving out GONE and MISSING entries.
+func countPresent(results []scanner.ScanResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Status != "GONE" && r.Status != "MISSING" {
+			n++
+		}
+	}
+	return n
+}
+
+// countFound returns the total of per-method host counts.
+func countFound(foundBy map[string]int) int {
+	n := 0
+	for _, c := range foundBy {
+		n += c
+	}
+	return n
+}
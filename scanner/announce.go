@@ -0,0 +1,233 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Multicast groups listened to for announcements.
+var (
+	mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+)
+
+// announcedModelKeys are mDNS TXT keys that carry a device model, tried in
+// order: AirPlay, AirPlay audio, Google Cast and printers.
+var announcedModelKeys = []string{"model", "am", "md", "ty"}
+
+// heardHost is what a host announced about itself over mDNS or SSDP.
+type heardHost struct {
+	method   string   // "MDNS" or "SSDP", whichever was heard first
+	name     string   // host name from an mDNS A record for its address
+	services []string // Bonjour service types, e.g. "_ipp._tcp"
+	model    string   // device model from mDNS TXT or the SSDP SERVER header
+}
+
+// listenAnnouncements joins the mDNS and SSDP multicast groups on ifi (the
+// system default if nil) for d, or until ctx is done, and returns what
+// every IPv4 sender announced, keyed by address. An error is returned only
+// if neither group could be joined.
+func listenAnnouncements(ctx context.Context, ifi *net.Interface, d time.Duration) (map[string]heardHost, error) {
+	heard := make(map[string]heardHost)
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	groups := map[string]*net.UDPAddr{"MDNS": mdnsGroup, "SSDP": ssdpGroup}
+	for method, group := range groups {
+		conn, err := net.ListenMulticastUDP("udp4", ifi, group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", group, err))
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(d))
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stop()
+			defer conn.Close()
+			buf := make([]byte, 9000)
+			for {
+				n, src, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return
+				}
+				ip := src.IP.To4()
+				if ip == nil {
+					continue
+				}
+				var a heardHost
+				if method == "MDNS" {
+					a = parseMDNSAnnouncement(buf[:n], ip)
+				} else {
+					a.model = ssdpProduct(headerValue(string(buf[:n]), "SERVER"))
+				}
+				mu.Lock()
+				heard[ip.String()] = mergeHeard(heard[ip.String()], method, a)
+				mu.Unlock()
+			}
+		}()
+	}
+	if len(errs) == len(groups) {
+		return heard, errors.Join(errs...)
+	}
+	wg.Wait()
+	return heard, errors.Join(errs...)
+}
+
+// mergeHeard adds a newly heard announcement a, sent over method, to h.
+func mergeHeard(h heardHost, method string, a heardHost) heardHost {
+	if h.method == "" {
+		h.method = method
+	}
+	if h.name == "" {
+		h.name = a.name
+	}
+	if h.model == "" {
+		h.model = a.model
+	}
+	for _, svc := range a.services {
+		if !slices.Contains(h.services, svc) {
+			h.services = append(h.services, svc)
+		}
+	}
+	slices.Sort(h.services)
+	return h
+}
+
+// parseMDNSAnnouncement extracts what an mDNS message sent by ip says
+// about it: the name of an A record for ip (e.g. "printer.local"), the
+// service types of PTR records and a model from TXT records.
+func parseMDNSAnnouncement(msg []byte, ip net.IP) heardHost {
+	var h heardHost
+	for _, rec := range parseDNSRecords(msg) {
+		switch rec.Type {
+		case dnsTypeA:
+			if h.name == "" && rec.Addr.Equal(ip) {
+				h.name = rec.Name
+			}
+		case dnsTypePTR:
+			if svc := announcedService(rec.Name); svc != "" && !slices.Contains(h.services, svc) {
+				h.services = append(h.services, svc)
+			}
+		case dnsTypeTXT:
+			for _, key := range announcedModelKeys {
+				if h.model != "" {
+					break
+				}
+				h.model = txtValue(rec.TXT, key)
+			}
+		}
+	}
+	return h
+}
+
+// announcedService returns the service type of a DNS-SD PTR record name,
+// such as "_ipp._tcp" for "_ipp._tcp.local", or "" for other names,
+// including the service type listing itself.
+func announcedService(name string) string {
+	t, ok := strings.CutSuffix(name, ".local")
+	if !ok || !strings.HasPrefix(t, "_") || strings.EqualFold(name, servicesMetaQuery) {
+		return ""
+	}
+	if !strings.HasSuffix(t, "._tcp") && !strings.HasSuffix(t, "._udp") {
+		return ""
+	}
+	// Subtypes ("_printer._sub._http._tcp") belong to their base type
+	if _, base, ok := strings.Cut(t, "._sub."); ok {
+		return base
+	}
+	return t
+}
+
+// headerValue returns the value of an HTTP-style header in an SSDP
+// message, or "" if it is missing.
+func headerValue(msg, key string) string {
+	for _, line := range strings.Split(msg, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// ssdpProduct returns the product part of an SSDP SERVER header, which
+// has the form "OS/version UPnP/1.0 product/version", such as
+// "Sonos/70.3 (ZPS1)" for "Linux UPnP/1.0 Sonos/70.3 (ZPS1)".
+func ssdpProduct(server string) string {
+	fields := strings.Fields(server)
+	for i, f := range fields {
+		if strings.HasPrefix(strings.ToUpper(f), "UPNP/") {
+			return strings.Join(fields[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// listenInterface returns the interface to listen for announcements on,
+// or nil for the system default.
+func (p *Plan) listenInterface() *net.Interface {
+	if p.Interface == nil {
+		return nil
+	}
+	ifi, err := net.InterfaceByName(p.Interface.Name)
+	if err != nil {
+		return nil
+	}
+	return ifi
+}
+
+// addHeard appends a result for each host in heard that is one of the
+// plan's hosts but not already in results, found by the method it was
+// heard over. The scanning host's own announcements are skipped.
+func (p *Plan) addHeard(results []ScanResult, heard map[string]heardHost) []ScanResult {
+	found := make(map[string]bool, len(results)+1)
+	if p.Interface != nil {
+		found[p.Interface.IP.String()] = true
+	}
+	for _, r := range results {
+		found[r.IP.String()] = true
+	}
+	for ipStr, h := range heard {
+		ip := net.ParseIP(ipStr).To4()
+		if ip == nil || found[ipStr] || !p.contains(ip) {
+			continue
+		}
+		results = append(results, ScanResult{IP: ip, Method: h.method, Target: p.names[ipStr]})
+	}
+	return results
+}
+
+// applyHeard fills in enriched results from what their hosts announced:
+// the host name if none was resolved, the model if none is known, and
+// the announced services alongside any browsed ones.
+func applyHeard(results []ScanResult, heard map[string]heardHost) {
+	for i := range results {
+		r := &results[i]
+		h, ok := heard[r.IP.String()]
+		if !ok {
+			continue
+		}
+		if h.name != "" && (r.Hostname == "-" || r.Hostname == "") {
+			r.Hostname = h.name
+		}
+		if r.Model == "" {
+			r.Model = h.model
+		}
+		for _, svc := range h.services {
+			if !slices.Contains(r.Services, svc) {
+				r.Services = append(r.Services, svc)
+			}
+		}
+		slices.Sort(r.Services)
+		r.Confidence = scoreConfidence(*r)
+	}
+}
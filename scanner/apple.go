@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// applePorts are TCP ports that indicate an Apple device: iDevice sync
+// (62078) and AirPlay (7000, 7100).
+var applePorts = []int{62078, 7000, 7100}
+
+// appleService describes an AirPlay-related Bonjour service and the TXT key
+// that carries the device model.
+type appleService struct {
+	name     string
+	modelKey string
+}
+
+var appleServices = []appleService{
+	{"_airplay._tcp.local", "model"},
+	{"_raop._tcp.local", "am"},
+}
+
+// isAppleCandidate reports whether any Apple-specific port is open.
+func isAppleCandidate(ports []int) bool {
+	for _, p := range applePorts {
+		if containsPort(ports, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupAppleDevice queries the host's AirPlay services over unicast mDNS and
+// returns the advertised device name and model (e.g. "Living Room",
+// "AppleTV6,2"). Either value is empty if not advertised.
+func LookupAppleDevice(ip string, timeout time.Duration) (name, model string) {
+	return lookupAppleDevice(context.Background(), ip, timeout)
+}
+
+// lookupAppleDevice is LookupAppleDevice, giving up once ctx is done.
+func lookupAppleDevice(ctx context.Context, ip string, timeout time.Duration) (name, model string) {
+	for _, svc := range appleServices {
+		records := mdnsQuery(ctx, ip, svc.name, dnsTypePTR, timeout)
+		instance := ""
+		for _, rec := range records {
+			if rec.Type == dnsTypePTR && strings.EqualFold(rec.Name, svc.name) {
+				instance = rec.Target
+				break
+			}
+		}
+		if instance == "" {
+			continue
+		}
+
+		// TXT is usually sent as an additional record; ask for it otherwise.
+		txt := findTXT(records, instance)
+		if txt == nil {
+			txt = findTXT(mdnsQuery(ctx, ip, instance, dnsTypeTXT, timeout), instance)
+		}
+
+		if name == "" {
+			name = instanceLabel(instance, svc.name)
+		}
+		if model == "" {
+			model = txtValue(txt, svc.modelKey)
+		}
+		if name != "" && model != "" {
+			break
+		}
+	}
+	return name, model
+}
+
+// findTXT returns the TXT strings for instance among records.
+func findTXT(records []dnsRecord, instance string) []string {
+	for _, rec := range records {
+		if rec.Type == dnsTypeTXT && strings.EqualFold(rec.Name, instance) {
+			return rec.TXT
+		}
+	}
+	return nil
+}
+
+// instanceLabel strips the service suffix from a service instance name.
+// RAOP instances are named "<MAC>@<name>", so the MAC prefix is dropped too.
+func instanceLabel(instance, service string) string {
+	label := strings.TrimSuffix(instance, "."+service)
+	if _, after, ok := strings.Cut(label, "@"); ok && strings.HasPrefix(service, "_raop.") {
+		label = after
+	}
+	return label
+}
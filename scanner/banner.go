@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"net"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// bannerMax is the longest banner kept, in bytes.
+const bannerMax = 120
+
+// bannerPayloads are the built-in nudges sent to services that say nothing
+// until the client speaks. Services that greet first (SSH, FTP, SMTP, ...)
+// need none; for SMTP the EHLO is answered after the greeting, so the
+// greeting is still what is kept.
+var bannerPayloads = map[int][]byte{
+	25:   []byte("EHLO localscan\r\n"),
+	80:   []byte("HEAD / HTTP/1.0\r\n\r\n"),
+	554:  []byte("OPTIONS * RTSP/1.0\r\nCSeq: 1\r\n\r\n"),
+	3000: []byte("HEAD / HTTP/1.0\r\n\r\n"),
+	5000: []byte("HEAD / HTTP/1.0\r\n\r\n"),
+	8008: []byte("HEAD / HTTP/1.0\r\n\r\n"),
+	8080: []byte("HEAD / HTTP/1.0\r\n\r\n"),
+	9090: []byte("HEAD / HTTP/1.0\r\n\r\n"),
+}
+
+// RTSPInfo describes an RTSP server that answered an OPTIONS request.
+type RTSPInfo struct {
+	Server  string   `json:"server,omitempty"`  // Server header, e.g. "Hikvision-Webs"
+	Methods []string `json:"methods,omitempty"` // methods listed in the Public header
+}
+
+// bannerTimeout is how long grabBanner waits for port's service: its
+// ScanOptions.PortTimeouts override, or the probe timeout.
+func (p *prober) bannerTimeout(port int) time.Duration {
+	if d, ok := p.portTimeout[port]; ok {
+		return d
+	}
+	return p.timeout
+}
+
+// grabBanner sends the nudge for port, if any, and returns the first line
+// the service sends back, trimmed to printable text, or "" if it sends
+// nothing within the probe timeout. A reply in RTSP form (port 554 is
+// sent an OPTIONS request) is also parsed into an RTSPInfo.
+func (p *prober) grabBanner(conn net.Conn, port int) (string, *RTSPInfo) {
+	conn.SetDeadline(time.Now().Add(p.bannerTimeout(port)))
+	payload, ok := p.tcpPayloads[port]
+	if !ok {
+		payload = bannerPayloads[port]
+	}
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			return "", nil
+		}
+		p.sent.tcpData(len(payload))
+	}
+
+	bufp := p.readBufs.Get().(*[]byte)
+	defer p.readBufs.Put(bufp)
+	n, _ := conn.Read(*bufp)
+	reply := string((*bufp)[:n])
+	return cleanBanner(reply), parseRTSP(reply)
+}
+
+// parseRTSP parses the status line and headers of an RTSP response, or
+// returns nil if reply is not one.
+func parseRTSP(reply string) *RTSPInfo {
+	if !strings.HasPrefix(reply, "RTSP/1.") {
+		return nil
+	}
+	info := &RTSPInfo{}
+	for _, line := range strings.Split(reply, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(name) {
+		case "server":
+			info.Server = cleanBanner(value)
+		case "public":
+			for _, m := range strings.Split(value, ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					info.Methods = append(info.Methods, m)
+				}
+			}
+		}
+	}
+	return info
+}
+
+// cleanBanner returns the first non-empty line of s with control and
+// non-printable characters removed, cut to bannerMax bytes.
+func cleanBanner(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Map(func(r rune) rune {
+			if r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+				return -1
+			}
+			return r
+		}, line)
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > bannerMax {
+				line = strings.ToValidUTF8(line[:bannerMax], "")
+			}
+			return line
+		}
+	}
+	return ""
+}
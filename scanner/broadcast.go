@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// echoReplyFrom matches the responder of a reply line printed by ping, e.g.
+// "64 bytes from 192.168.1.5: icmp_seq=1 ttl=64 time=0.52 ms".
+var echoReplyFrom = regexp.MustCompile(`bytes from (\d+\.\d+\.\d+\.\d+)`)
+
+// broadcastPing sends ICMP echo requests to the broadcast address of each
+// network on the interface and returns the hosts that replied, excluding
+// the interface's own addresses. Many hosts ignore broadcast pings (Linux
+// does by default), so the result supplements the per-host sweep rather
+// than replacing it. Windows ping cannot target a broadcast address, so
+// nothing is returned there. Once ctx is done the pings are killed and
+// the replies so far are returned.
+func broadcastPing(ctx context.Context, info *InterfaceInfo, timeout time.Duration) []net.IP {
+	if runtime.GOOS == "windows" || info == nil {
+		return nil
+	}
+	own := make(map[string]bool)
+	for _, n := range info.Networks {
+		own[n.IP.String()] = true
+	}
+	seen := make(map[string]bool)
+	var hosts []net.IP
+	for _, n := range info.Networks {
+		bcast := broadcastAddr(n)
+		if bcast == nil {
+			continue
+		}
+		for _, ip := range pingBroadcast(ctx, bcast, timeout, info.IP) {
+			key := ip.String()
+			if own[key] || seen[key] || !n.Contains(ip) {
+				continue
+			}
+			seen[key] = true
+			hosts = append(hosts, ip)
+		}
+	}
+	return hosts
+}
+
+// broadcastAddr returns the directed broadcast address of network, or nil
+// for IPv6 and for /31 and /32 networks, which have none.
+func broadcastAddr(network *net.IPNet) net.IP {
+	ip := network.IP.To4()
+	ones, bits := network.Mask.Size()
+	if ip == nil || bits != 32 || ones > 30 {
+		return nil
+	}
+	mask := net.IP(network.Mask).To4()
+	bcast := make(net.IP, 4)
+	for i := range bcast {
+		bcast[i] = ip[i] | ^mask[i]
+	}
+	return bcast
+}
+
+// pingBroadcast runs the system ping against a broadcast address for about
+// twice the probe timeout (at least a second) and parses the responders
+// from its output, or from what it printed before ctx was done.
+func pingBroadcast(ctx context.Context, bcast net.IP, timeout time.Duration, source net.IP) []net.IP {
+	secs := max(1, int((2 * timeout).Seconds()))
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		args = []string{"-t", fmt.Sprintf("%d", secs)}
+		if source != nil {
+			args = append(args, "-S", source.String())
+		}
+	default: // linux
+		args = []string{"-b", "-w", fmt.Sprintf("%d", secs)}
+		if source != nil {
+			args = append(args, "-I", source.String())
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(secs+1)*time.Second)
+	defer cancel()
+	// ping exits non-zero when nothing answered; the output is all we need
+	out, _ := exec.CommandContext(ctx, "ping", append(args, bcast.String())...).Output()
+
+	var hosts []net.IP
+	for _, m := range echoReplyFrom.FindAllSubmatch(out, -1) {
+		if ip := net.ParseIP(string(m[1])).To4(); ip != nil {
+			hosts = append(hosts, ip)
+		}
+	}
+	return hosts
+}
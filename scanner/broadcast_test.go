@@ -0,0 +1,23 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestBroadcastPingCancel checks that a broadcast ping with a long timeout
+// gives up once ctx is done instead of waiting the timeout out.
+func TestBroadcastPingCancel(t *testing.T) {
+	_, network, _ := net.ParseCIDR("127.0.0.0/8")
+	info := &InterfaceInfo{IP: net.IPv4(127, 0, 0, 1), Network: network, Networks: []*net.IPNet{network}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	broadcastPing(ctx, info, 10*time.Second)
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("broadcastPing took %v after a 100ms deadline", elapsed)
+	}
+}
@@ -0,0 +1,192 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ProbeCache keeps the port data of each host's last full probe across
+// repeated scans of the same network, such as a monitoring loop calling
+// Plan.Run every minute. Set it as ScanOptions.Cache: a host fully probed
+// less than FullEvery ago only gets a cheap liveness check (see
+// ScanOptions.DiscoveryOnly) and is reported with its cached open ports,
+// banners and RTSP details, which cuts the traffic of continuous
+// monitoring while presence is still checked every scan. A host that does
+// not answer is dropped from the cache, so it is fully probed when it
+// returns, and so is one whose cached probe covered other TCP ports than
+// the scan's. Hosts given with their own ports bypass the cache. Separate runs of a program can share one with LoadProbeCache
+// and ProbeCache.Save. A ProbeCache is safe for concurrent use.
+type ProbeCache struct {
+	FullEvery time.Duration // how often a host is fully probed; every scan if 0
+
+	mu    sync.Mutex
+	hosts map[string]cachedProbe
+}
+
+// cachedProbe is the result of a host's last full probe of the TCP ports
+// in probed.
+type cachedProbe struct {
+	at     time.Time
+	probed []int
+	ports  portStates
+}
+
+// NewProbeCache returns an empty cache that fully probes each host once
+// every fullEvery.
+func NewProbeCache(fullEvery time.Duration) *ProbeCache {
+	return &ProbeCache{FullEvery: fullEvery, hosts: make(map[string]cachedProbe)}
+}
+
+// Len returns the number of hosts in the cache.
+func (c *ProbeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.hosts)
+}
+
+// fresh returns the cached ports of ip if its last full probe covered the
+// TCP ports in probed and was less than FullEvery before now.
+func (c *ProbeCache) fresh(ip string, probed []int, now time.Time) (portStates, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.hosts[ip]
+	if !ok || now.Sub(e.at) >= c.FullEvery || !slices.Equal(e.probed, probed) {
+		return portStates{}, false
+	}
+	return e.ports, true
+}
+
+// store records a full probe of the TCP ports in probed on ip at now.
+func (c *ProbeCache) store(ip string, probed []int, ports portStates, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hosts == nil {
+		c.hosts = make(map[string]cachedProbe)
+	}
+	c.hosts[ip] = cachedProbe{at: now, probed: slices.Clone(probed), ports: ports.clone()}
+}
+
+func probeCachePath() string {
+	return filepath.Join(DataDir(), "probe-cache.json")
+}
+
+// cacheFileEntry is the form of a cached probe in probe-cache.json. The
+// RTT is left out; it is refreshed by every liveness check.
+type cacheFileEntry struct {
+	At      time.Time      `json:"at"`
+	Probed  []int          `json:"probed_ports"`
+	Open    []int          `json:"open_ports"`
+	Closed  []int          `json:"closed_ports,omitempty"`
+	Banners map[int]string `json:"banners,omitempty"`
+	RTSP    *RTSPInfo      `json:"rtsp,omitempty"`
+}
+
+// LoadProbeCache reads the cache saved by ProbeCache.Save from
+// ~/.localscan/probe-cache.json, for scans run repeatedly as separate
+// processes. Hosts whose full probe is already older than fullEvery are
+// left out. A missing file yields an empty cache.
+func LoadProbeCache(fullEvery time.Duration) (*ProbeCache, error) {
+	c := NewProbeCache(fullEvery)
+	data, err := os.ReadFile(probeCachePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	var entries map[string]cacheFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for ip, e := range entries {
+		if now.Sub(e.At) < fullEvery {
+			c.hosts[ip] = cachedProbe{at: e.At, probed: e.Probed, ports: portStates{open: e.Open, closed: e.Closed, banners: e.Banners, rtsp: e.RTSP}}
+		}
+	}
+	return c, nil
+}
+
+// Save writes the cache to ~/.localscan/probe-cache.json.
+func (c *ProbeCache) Save() error {
+	c.mu.Lock()
+	entries := make(map[string]cacheFileEntry, len(c.hosts))
+	for ip, e := range c.hosts {
+		entries[ip] = cacheFileEntry{At: e.at, Probed: e.probed, Open: e.ports.open, Closed: e.ports.closed, Banners: e.ports.banners, RTSP: e.ports.rtsp}
+	}
+	c.mu.Unlock()
+
+	p := probeCachePath()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, false)
+}
+
+// forget drops ip, so it is fully probed the next time it answers.
+func (c *ProbeCache) forget(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.hosts, ip)
+}
+
+// clone returns a copy of s that shares nothing with it, so results built
+// from cached ports can be changed freely.
+func (s portStates) clone() portStates {
+	s.open = slices.Clone(s.open)
+	s.closed = slices.Clone(s.closed)
+	s.banners = maps.Clone(s.banners)
+	s.answers = maps.Clone(s.answers)
+	if s.rtsp != nil {
+		rtsp := *s.rtsp
+		rtsp.Methods = slices.Clone(rtsp.Methods)
+		s.rtsp = &rtsp
+	}
+	return s
+}
+
+// probeHost is detectHost through the probe cache: a host with a fresh
+// full probe only has its liveness checked and keeps its cached ports,
+// with the RTT of the new check. A host given with its own ports is always
+// probed on them, since the cache is kept for the scan's port list.
+func (p *prober) probeHost(ctx context.Context, ip string) (string, portStates, error) {
+	if _, own := p.hostPorts[ip]; own || p.cache == nil {
+		return p.detectHost(ctx, ip)
+	}
+	now := time.Now()
+	if cached, ok := p.cache.fresh(ip, p.tcpPorts, now); ok {
+		method, ports, err := p.detect(ctx, ip, true)
+		if method == "" {
+			if ctx.Err() == nil {
+				p.cache.forget(ip)
+			}
+			return method, ports, err
+		}
+		if ports.rtt > 0 {
+			cached.rtt = ports.rtt
+		}
+		cached.answers = ports.answers
+		return method, cached.clone(), nil
+	}
+	method, ports, err := p.detectHost(ctx, ip)
+	switch {
+	case ctx.Err() != nil:
+		// Cut short, so the probe says nothing certain about the host
+	case method == "":
+		p.cache.forget(ip)
+	case !p.aliveOnly:
+		p.cache.store(ip, p.tcpPorts, ports, now)
+	}
+	return method, ports, err
+}
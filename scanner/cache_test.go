@@ -0,0 +1,190 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProbeCacheFresh(t *testing.T) {
+	now := time.Now()
+	c := NewProbeCache(time.Minute)
+	c.store("10.0.0.1", nil, portStates{open: []int{22, 80}}, now)
+
+	tests := []struct {
+		ip    string
+		at    time.Time
+		fresh bool
+	}{
+		{"10.0.0.1", now, true},
+		{"10.0.0.1", now.Add(59 * time.Second), true},
+		{"10.0.0.1", now.Add(time.Minute), false},
+		{"10.0.0.1", now.Add(time.Hour), false},
+		{"10.0.0.2", now, false},
+	}
+	for _, tt := range tests {
+		ports, ok := c.fresh(tt.ip, nil, tt.at)
+		if ok != tt.fresh {
+			t.Errorf("fresh(%s, +%v) = %v, want %v", tt.ip, tt.at.Sub(now), ok, tt.fresh)
+		}
+		if ok && !slices.Equal(ports.open, []int{22, 80}) {
+			t.Errorf("fresh(%s) ports = %v, want [22 80]", tt.ip, ports.open)
+		}
+	}
+
+	c.forget("10.0.0.1")
+	if _, ok := c.fresh("10.0.0.1", nil, now); ok || c.Len() != 0 {
+		t.Error("host still cached after forget")
+	}
+}
+
+func TestProbeCacheZeroFullEvery(t *testing.T) {
+	c := NewProbeCache(0)
+	now := time.Now()
+	c.store("10.0.0.1", nil, portStates{open: []int{22}}, now)
+	if _, ok := c.fresh("10.0.0.1", nil, now); ok {
+		t.Error("host is fresh with FullEvery 0; every scan should probe fully")
+	}
+}
+
+func TestProbeCachePortSet(t *testing.T) {
+	c := NewProbeCache(time.Minute)
+	now := time.Now()
+	c.store("10.0.0.1", []int{22, 80}, portStates{open: []int{22}}, now)
+	if _, ok := c.fresh("10.0.0.1", []int{22, 80}, now); !ok {
+		t.Error("host not fresh for the ports it was probed on")
+	}
+	if _, ok := c.fresh("10.0.0.1", []int{22, 80, 443}, now); ok {
+		t.Error("host fresh for ports it was never probed on")
+	}
+}
+
+func TestProbeCacheStoresCopy(t *testing.T) {
+	c := NewProbeCache(time.Minute)
+	now := time.Now()
+	ports := portStates{open: []int{22}, banners: map[int]string{22: "SSH-2.0"}}
+	c.store("10.0.0.1", nil, ports, now)
+	ports.open[0] = 23
+	ports.banners[22] = "changed"
+
+	cached, _ := c.fresh("10.0.0.1", nil, now)
+	if cached.open[0] != 22 || cached.banners[22] != "SSH-2.0" {
+		t.Errorf("cache shares memory with the stored ports: %v %v", cached.open, cached.banners)
+	}
+}
+
+// TestProbeHostSkipsFullProbe closes the only open port after the first
+// scan. While the cache is fresh the host is still reported with it, since
+// only a liveness check is sent; once it expires the full probe runs again.
+func TestProbeHostSkipsFullProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := ln.Addr().(*net.TCPAddr).Port
+	closed := freePort(t)
+
+	cache := NewProbeCache(time.Hour)
+	pr := newProber(ScanOptions{
+		Timeout:     time.Second,
+		MethodOrder: []string{MethodTCP},
+		TCPPorts:    []int{closed, open},
+		Cache:       cache,
+	}.withDefaults())
+
+	method, ports, _ := pr.probeHost(context.Background(), "127.0.0.1")
+	if method != "TCP" || !slices.Equal(ports.open, []int{open}) {
+		t.Fatalf("first probe = %q %v, want TCP [%d]", method, ports.open, open)
+	}
+	ln.Close()
+	connects := pr.sent.stats().TCPConnects
+
+	method, ports, _ = pr.probeHost(context.Background(), "127.0.0.1")
+	if method != "TCP" || !slices.Equal(ports.open, []int{open}) {
+		t.Errorf("cached probe = %q %v, want TCP [%d] from the cache", method, ports.open, open)
+	}
+	if n := pr.sent.stats().TCPConnects - connects; n != 1 {
+		t.Errorf("cached probe made %d TCP connects, want 1 liveness check", n)
+	}
+
+	cache.FullEvery = 0
+	method, ports, _ = pr.probeHost(context.Background(), "127.0.0.1")
+	if method != "TCP" || len(ports.open) != 0 {
+		t.Errorf("probe after expiry = %q %v, want TCP with no open ports", method, ports.open)
+	}
+}
+
+// TestProbeHostOwnPortsBypassCache checks that a host given as ip:port is
+// probed on its own ports even when the cache holds a fresh entry for it,
+// and that the entry is left alone.
+func TestProbeHostOwnPortsBypassCache(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go acceptAndClose(ln)
+	open := ln.Addr().(*net.TCPAddr).Port
+
+	cache := NewProbeCache(time.Hour)
+	pr := newProber(ScanOptions{
+		Timeout:     time.Second,
+		MethodOrder: []string{MethodTCP},
+		Cache:       cache,
+		hostPorts:   map[string][]int{"127.0.0.1": {open}},
+	}.withDefaults())
+	cache.store("127.0.0.1", pr.tcpPorts, portStates{open: []int{22}}, time.Now())
+
+	method, ports, _ := pr.probeHost(context.Background(), "127.0.0.1")
+	if method != "TCP" || !slices.Equal(ports.open, []int{open}) {
+		t.Errorf("probeHost = %q %v, want TCP [%d] from its own port", method, ports.open, open)
+	}
+	if cached, ok := cache.fresh("127.0.0.1", pr.tcpPorts, time.Now()); !ok || !slices.Equal(cached.open, []int{22}) {
+		t.Errorf("cache entry = %v %v, want it untouched", cached.open, ok)
+	}
+}
+
+func TestProbeCacheSaveLoad(t *testing.T) {
+	withDataDir(t)
+	now := time.Now()
+	c := NewProbeCache(time.Hour)
+	c.store("10.0.0.1", []int{80, 554}, portStates{open: []int{554}, rtsp: &RTSPInfo{Server: "cam"}}, now)
+	c.store("10.0.0.2", nil, portStates{open: []int{80}}, now.Add(-2*time.Hour))
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadProbeCache(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 1 {
+		t.Fatalf("loaded %d hosts, want 1 (the stale one left out)", loaded.Len())
+	}
+	ports, ok := loaded.fresh("10.0.0.1", []int{80, 554}, now)
+	if !ok || !slices.Equal(ports.open, []int{554}) || ports.rtsp == nil || ports.rtsp.Server != "cam" {
+		t.Errorf("loaded ports = %+v, %v", ports, ok)
+	}
+}
+
+func TestLoadProbeCacheMissing(t *testing.T) {
+	withDataDir(t)
+	c, err := LoadProbeCache(time.Hour)
+	if err != nil || c.Len() != 0 {
+		t.Errorf("LoadProbeCache without a file = %v, %v; want an empty cache", c.Len(), err)
+	}
+}
+
+// freePort returns a loopback TCP port with nothing listening on it.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
@@ -0,0 +1,63 @@
+package scanner
+
+import (
+	"bytes"
+	"net"
+	"slices"
+)
+
+// Port check states for PortCheck.State.
+const (
+	CheckOpen     = "open"     // the port accepted a connection
+	CheckClosed   = "closed"   // the port refused the connection: the host is up
+	CheckFiltered = "filtered" // the port did not answer, but the host was found otherwise
+	CheckDown     = "down"     // the host was not found at all
+)
+
+// PortCheck is the outcome of probing one port of a "host:port" target.
+type PortCheck struct {
+	IP    net.IP `json:"ip"`
+	Port  int    `json:"port"`
+	State string `json:"state"`
+}
+
+// Up reports whether the port accepted a connection.
+func (c PortCheck) Up() bool {
+	return c.State == CheckOpen
+}
+
+// portChecks returns the state of each port of the plan's "host:port"
+// targets, sorted by IP and port, or nil if it has none.
+func (p *Plan) portChecks(results []ScanResult) []PortCheck {
+	if len(p.ports) == 0 {
+		return nil
+	}
+	byIP := make(map[string]*ScanResult, len(results))
+	for i := range results {
+		byIP[results[i].IP.String()] = &results[i]
+	}
+	var checks []PortCheck
+	for ip, ports := range p.ports {
+		r := byIP[ip]
+		for _, port := range ports {
+			c := PortCheck{IP: net.ParseIP(ip).To4(), Port: port, State: CheckDown}
+			switch {
+			case r == nil:
+			case slices.Contains(r.OpenPorts, port):
+				c.State = CheckOpen
+			case slices.Contains(r.ClosedPorts, port):
+				c.State = CheckClosed
+			default:
+				c.State = CheckFiltered
+			}
+			checks = append(checks, c)
+		}
+	}
+	slices.SortFunc(checks, func(a, b PortCheck) int {
+		if c := bytes.Compare(a.IP, b.IP); c != 0 {
+			return c
+		}
+		return a.Port - b.Port
+	})
+	return checks
+}
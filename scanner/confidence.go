@@ -0,0 +1,61 @@
+package scanner
+
+// methodConfidence is the base confidence that a host detected by a method
+// is a real device. ARP is a layer-2 answer from the device itself; a TCP
+// handshake or reset needs a live TCP stack; a UDP reply is validated
+// against the query, and an mDNS or SSDP announcement heard in passive mode
+// is as good; a single ICMP echo reply is the weakest evidence, as it may be
+// lost or answered by a proxy.
+var methodConfidence = map[string]int{
+	"SELF": 100,
+	"ARP":  90,
+	"TCP":  80,
+	"UDP":  70,
+	"MDNS": 70,
+	"SSDP": 70,
+	"ICMP": 60,
+}
+
+// Confidence bonuses for corroborating evidence.
+const (
+	confidenceMethod    = 10 // each further method that answered
+	confidenceRepeat    = 5  // each further answer, up to confidenceRepeatMax
+	confidenceRepeatMax = 10
+	confidenceMAC       = 15 // the host also answered ARP (not for ARP itself)
+	confidenceName      = 5  // hostname or Bonjour name resolved
+)
+
+// scoreConfidence rates from 0 to 100 how sure the scan is that r is a real
+// device: the base of the strongest method that answered, plus bonuses for
+// every other method that answered, for repeated answers (such as several
+// TCP ports accepting or refusing a connection) and for independent
+// evidence (a MAC in the ARP table, a resolved name). A result without
+// Answers counts as one answer to its Method.
+func scoreConfidence(r ScanResult) int {
+	answers := r.Answers
+	if len(answers) == 0 {
+		answers = map[string]int{r.Method: 1}
+	}
+	score, methods, repeats := 0, 0, 0
+	for m, n := range answers {
+		base, ok := methodConfidence[m]
+		if !ok || n <= 0 {
+			continue
+		}
+		score = max(score, base)
+		methods++
+		repeats += n - 1
+	}
+	if methods == 0 {
+		return 0
+	}
+	score += (methods - 1) * confidenceMethod
+	score += min(repeats*confidenceRepeat, confidenceRepeatMax)
+	if answers["ARP"] == 0 && r.MAC != "" && r.MAC != "-" {
+		score += confidenceMAC
+	}
+	if (r.Hostname != "" && r.Hostname != "-") || r.DeviceName != "" {
+		score += confidenceName
+	}
+	return min(score, 100)
+}
@@ -0,0 +1,25 @@
+package scanner
+
+import "testing"
+
+func TestScoreConfidence(t *testing.T) {
+	tests := []struct {
+		name string
+		r    ScanResult
+		want int
+	}{
+		{"one ICMP reply", ScanResult{Method: "ICMP", Answers: map[string]int{"ICMP": 1}}, 60},
+		{"no answers recorded", ScanResult{Method: "TCP"}, 80},
+		{"several TCP ports", ScanResult{Method: "TCP", Answers: map[string]int{"TCP": 4}}, 90},
+		{"ICMP and TCP", ScanResult{Method: "ICMP", Answers: map[string]int{"ICMP": 1, "TCP": 2}}, 95},
+		{"ICMP with MAC and name", ScanResult{Method: "ICMP", Answers: map[string]int{"ICMP": 1}, MAC: "aa:bb:cc:dd:ee:ff", Hostname: "nas"}, 80},
+		{"ARP answer, no MAC bonus", ScanResult{Method: "ARP", MAC: "aa:bb:cc:dd:ee:ff"}, 90},
+		{"capped", ScanResult{Method: "TCP", Answers: map[string]int{"TCP": 9, "ICMP": 1, "UDP": 1}, MAC: "aa:bb:cc:dd:ee:ff"}, 100},
+		{"unknown method", ScanResult{Method: "-"}, 0},
+	}
+	for _, tt := range tests {
+		if got := scoreConfidence(tt.r); got != tt.want {
+			t.Errorf("%s: scoreConfidence = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package scanner
+
+import "testing"
+
+// withDataDir points DataDir at a temporary directory for the test.
+func withDataDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	saved := dataDir
+	dataDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { dataDir = saved })
+	return dir
+}
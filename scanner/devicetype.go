@@ -0,0 +1,119 @@
+package scanner
+
+import "strings"
+
+// DeviceRule classifies a host as Type when its vendor starts with Vendor
+// (case-insensitive) and, if Ports is set, at least one of those TCP ports
+// is open.
+type DeviceRule struct {
+	Vendor string `json:"vendor"`
+	Ports  []int  `json:"ports,omitempty"`
+	Type   string `json:"type"`
+}
+
+// deviceRules are the built-in classification rules, checked after any
+// user rules. Rules with ports come before the vendor-only fallbacks.
+var deviceRules = []DeviceRule{
+	{Vendor: "Espressif", Ports: []int{80}, Type: "ESP IoT device"},
+	{Vendor: "Espressif", Ports: []int{1883, 8883}, Type: "MQTT IoT device"},
+	{Vendor: "Hikvision", Ports: []int{554}, Type: "IP camera"},
+	{Vendor: "Ubiquiti", Ports: []int{22, 443}, Type: "network gear"},
+	{Vendor: "MikroTik", Ports: []int{22, 23, 80}, Type: "network gear"},
+	{Vendor: "Cisco", Ports: []int{22, 23}, Type: "network gear"},
+	{Vendor: "Netgear", Ports: []int{80, 443}, Type: "network gear"},
+	{Vendor: "HP", Ports: []int{9100}, Type: "printer"},
+	{Vendor: "Epson", Ports: []int{9100}, Type: "printer"},
+	{Vendor: "Brother", Ports: []int{9100}, Type: "printer"},
+	{Vendor: "Canon", Ports: []int{9100}, Type: "printer"},
+	{Vendor: "Synology", Ports: []int{5000, 5001, 445}, Type: "NAS"},
+	{Vendor: "QNAP", Ports: []int{80, 443, 445}, Type: "NAS"},
+	{Vendor: "Google", Ports: []int{8008, 8009}, Type: "media streamer"},
+	{Vendor: "Raspberry Pi", Ports: []int{22}, Type: "single-board computer"},
+	{Vendor: "Espressif", Type: "IoT device"},
+	{Vendor: "Hikvision", Type: "IP camera"},
+	{Vendor: "Sonos", Type: "speaker"},
+	{Vendor: "Roku", Type: "media streamer"},
+	{Vendor: "Philips", Type: "smart lighting"},
+	{Vendor: "Signify", Type: "smart lighting"},
+}
+
+// vmOUIs are the MAC prefixes hypervisors assign to virtual NICs. QEMU's
+// 52:54:00 is locally administered, so its vendor shows as randomized and
+// only the prefix gives it away.
+var vmOUIs = map[string]string{
+	"00:50:56": "VMware",
+	"00:0C:29": "VMware",
+	"00:05:69": "VMware",
+	"08:00:27": "VirtualBox",
+	"00:15:5D": "Hyper-V",
+	"52:54:00": "QEMU/KVM",
+	"00:16:3E": "Xen",
+	"00:1C:42": "Parallels",
+}
+
+// vmType is the device type of hosts with a hypervisor MAC prefix.
+const vmType = "VM"
+
+// virtualMAC returns the hypervisor that assigned mac, or "" if mac does
+// not have a known virtual NIC prefix.
+func virtualMAC(mac string) string {
+	if len(mac) < 8 {
+		return ""
+	}
+	return vmOUIs[strings.ToUpper(mac[:8])]
+}
+
+// classifyDevice returns the type of the first rule r matches, trying
+// extra before the built-in rules, or "" if none matches. Hosts with a
+// hypervisor MAC prefix are vmType unless one of the extra rules matches,
+// and hosts that answered RTSP fall back to "IP camera".
+func classifyDevice(r ScanResult, extra []DeviceRule) string {
+	vendor := strings.ToLower(r.Vendor)
+	if r.Vendor == "-" {
+		vendor = ""
+	}
+	if t := matchRules(extra, vendor, r.OpenPorts); t != "" {
+		return t
+	}
+	if virtualMAC(r.MAC) != "" {
+		return vmType
+	}
+	if t := matchRules(deviceRules, vendor, r.OpenPorts); t != "" {
+		return t
+	}
+	if r.RTSP != nil {
+		return "IP camera"
+	}
+	return ""
+}
+
+// matchRules returns the type of the first rule that matches a lower-cased
+// vendor name with the given open ports, or "" if none does.
+func matchRules(rules []DeviceRule, vendor string, ports []int) string {
+	if vendor == "" {
+		return ""
+	}
+	for _, rule := range rules {
+		if rule.matches(vendor, ports) {
+			return rule.Type
+		}
+	}
+	return ""
+}
+
+// matches reports whether the rule applies to a lower-cased vendor name
+// with the given open ports.
+func (rule DeviceRule) matches(vendor string, ports []int) bool {
+	if rule.Vendor == "" || !strings.HasPrefix(vendor, strings.ToLower(rule.Vendor)) {
+		return false
+	}
+	if len(rule.Ports) == 0 {
+		return true
+	}
+	for _, p := range rule.Ports {
+		if containsPort(ports, p) {
+			return true
+		}
+	}
+	return false
+}
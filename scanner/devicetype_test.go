@@ -0,0 +1,40 @@
+package scanner
+
+import "testing"
+
+func TestVirtualMAC(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want string
+	}{
+		{"00:50:56:12:34:56", "VMware"},
+		{"00:0c:29:ab:cd:ef", "VMware"},
+		{"00:05:69:00:00:01", "VMware"},
+		{"08:00:27:AA:BB:CC", "VirtualBox"},
+		{"00:15:5D:01:02:03", "Hyper-V"},
+		{"52:54:00:12:34:56", "QEMU/KVM"},
+		{"00:16:3E:00:00:01", "Xen"},
+		{"00:1C:42:00:00:01", "Parallels"},
+		{"DC:A6:32:00:00:01", ""}, // Raspberry Pi
+		{"52:54:01:12:34:56", ""}, // locally administered, not QEMU's
+		{"00:50:5", ""},
+		{"", ""},
+		{"-", ""},
+	}
+	for _, tt := range tests {
+		if got := virtualMAC(tt.mac); got != tt.want {
+			t.Errorf("virtualMAC(%q) = %q, want %q", tt.mac, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyVM(t *testing.T) {
+	vm := ScanResult{MAC: "08:00:27:AA:BB:CC", Vendor: "PCS Systemtechnik GmbH", OpenPorts: []int{22}}
+	if got := classifyDevice(vm, nil); got != vmType {
+		t.Errorf("classifyDevice(VirtualBox NIC) = %q, want %q", got, vmType)
+	}
+	extra := []DeviceRule{{Type: "Build server", Vendor: "pcs"}}
+	if got := classifyDevice(vm, extra); got != "Build server" {
+		t.Errorf("classifyDevice with an extra rule = %q, want the rule's type", got)
+	}
+}
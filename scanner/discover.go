@@ -0,0 +1,636 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxTimeoutJitter is the largest valid ScanOptions.TimeoutJitter.
+const MaxTimeoutJitter = 0.5
+
+// Defaults used when the corresponding ScanOptions field is zero.
+const (
+	MaxAutoWorkers = 256 // upper bound for the automatic worker count
+	DefaultTimeout = 500 * time.Millisecond
+)
+
+// workersPerCPU scales the automatic worker count; probes mostly wait on
+// the network, so many more workers than CPUs pay off.
+const workersPerCPU = 32
+
+// AutoWorkers returns the worker count used when ScanOptions.Workers is zero:
+// min(hosts, 32 × CPUs), bounded to 1..MaxAutoWorkers.
+func AutoWorkers(hosts int) int {
+	return max(1, min(hosts, workersPerCPU*runtime.NumCPU(), MaxAutoWorkers))
+}
+
+// ScanOptions configures Discover and the lower-level scan functions.
+// The zero value scans the auto-detected interface network with defaults.
+type ScanOptions struct {
+	// Target selection
+	Interface      string   // interface name ("" = auto-detect)
+	IncludeVirtual bool     // allow auto-detection to pick VPN/virtual interfaces
+	Targets        []string // as for ResolveTargets; the interface networks if empty
+	VLAN           int      // scan through the 802.1Q subinterface for this VLAN ID, which must exist (Linux)
+
+	// Probing
+	Workers      int            // concurrent workers (AutoWorkers if 0)
+	MaxHosts     int            // stop probing once this many hosts are found (no limit if 0)
+	SourceIP     net.IP         // local address to send probes from (see NewPlan)
+	Timeout      time.Duration  // per-probe timeout (DefaultTimeout if 0)
+	TCPMode      string         // TCPConnect (default) or TCPSYN
+	MethodOrder  []string       // probe methods in order (DefaultMethodOrder if empty)
+	TCPPorts     []int          // TCP ports to probe (the built-in list if empty)
+	UDPPorts     []int          // UDP ports to probe (the built-in list if empty)
+	UDPPayloads  map[int][]byte // custom UDP probe packets per port
+	UDPProbes    []UDPProbe     // custom UDP probes with reply matching
+	ARPSettle    time.Duration  // time to keep re-reading the ARP table after probing
+	RecordClosed bool           // keep refused TCP ports in ScanResult.ClosedPorts
+
+	// PortTimeouts overrides Timeout for individual TCP ports, so services
+	// that are slow to accept (TLS on 443, RTSP on 554, IPP on 631) can be
+	// given longer without raising Timeout for every port. Banners are
+	// read within the same time. In SYN mode, all ports wait for the
+	// longest.
+	PortTimeouts map[int]time.Duration
+
+	// TimeoutJitter varies each probe's timeout at random by up to this
+	// fraction of Timeout either way (0.1 for ±10%), spreading out the
+	// moment probes sent together give up and their retries or follow-ups
+	// go out. Zero disables it; larger values than MaxTimeoutJitter are
+	// capped.
+	TimeoutJitter float64
+
+	// Cache, if set, lets repeated scans skip the full probe of hosts that
+	// were fully probed recently (see ProbeCache).
+	Cache *ProbeCache
+
+	// DiscoveryOnly only establishes which hosts are alive: the TCP probe
+	// stops at the first port that answers, or is skipped if another
+	// method found the host, and no ports are reported.
+	DiscoveryOnly bool
+
+	// TwoPhase first finds the live hosts quietly, by ICMP and the ARP
+	// table, then sends the TCP and UDP probes only to them, which is much
+	// faster on sparse networks. Hosts that ignore pings and are not on a
+	// local network are missed. MethodOrder must include ICMP.
+	TwoPhase bool
+
+	// Banners reads the first line each open TCP port sends into
+	// ScanResult.Banners, after sending a nudge to services that wait for
+	// the client (HTTP, RTSP, ...). TCPPayloads adds or replaces nudges per
+	// port. Banners are not read in SYN mode.
+	Banners     bool
+	TCPPayloads map[int][]byte
+
+	// ReadLimit is the size in bytes of the buffer that UDP replies,
+	// banners and SNMP answers are read into (DefaultReadLimit if 0).
+	// Longer replies are truncated.
+	ReadLimit int
+
+	// SNMPVersion is the SNMP version of the UDP probe: SNMPv1 (default) or
+	// SNMPv2c. With SNMPCommunities set, the probe uses the first community,
+	// and every found host is asked for its sysDescr with each community in
+	// turn, recording the first that works in ScanResult.SNMP.
+	SNMPVersion     string
+	SNMPCommunities []string
+
+	// BroadcastPing pings the broadcast address of each interface network
+	// before the sweep. Hosts that reply count as found by ICMP without
+	// being pinged again; the other probes still run for open ports.
+	BroadcastPing bool
+
+	hostPorts map[string][]int // hosts whose TCP probe is limited to the given ports
+
+	// Listen, if positive, joins the mDNS and SSDP multicast groups for
+	// that long from the start of the scan and merges what hosts announce
+	// into the results: their host names, Bonjour services and models,
+	// and hosts that were not found by any probe (Method "MDNS" or
+	// "SSDP"). Run waits for it if the sweep finishes first.
+	Listen time.Duration
+
+	// Passive sends no probes at all: Run reports the hosts in the ARP
+	// table and, with Listen, those heard announcing themselves. Only hosts
+	// that recently talked to this machine or announced themselves are
+	// found.
+	Passive bool
+
+	// Enrichment
+	IncludeSelf bool // add a SELF result for the scanning host
+	HTTP        bool // probe HTTP on port 80 for redirects / captive portals
+	SkipDNS     bool // do not resolve hostnames
+	SkipVendor  bool // do not look up MAC vendors
+
+	DNSTimeout   time.Duration     // per-host reverse lookup timeout (DefaultDNSTimeout if 0)
+	MDNSServices bool              // enumerate each host's advertised Bonjour services
+	Labels       map[string]string // normalized MAC -> friendly name (see LoadLabels)
+	DeviceRules  []DeviceRule      // device type rules tried before the built-in ones
+	Vendors      map[string]string // normalized MAC prefix -> vendor (see NormalizeMACPrefix), over the built-in table
+	EnrichHook   func(*ScanResult) // called once with each final result, SELF included (see EnrichOptions.Hook)
+
+	// RouterHostnames fills unresolved hostnames from the gateway's DHCP
+	// lease table (OpenWrt ubus or FRITZ!Box TR-064), using Router to log in.
+	RouterHostnames bool
+	Router          RouterCredentials
+}
+
+func (o ScanOptions) withDefaults() ScanOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.TCPMode == "" {
+		o.TCPMode = TCPConnect
+	}
+	return o
+}
+
+// Plan is a resolved set of hosts to scan, produced by NewPlan. Hosts are
+// enumerated lazily, so a plan for a /8 is as cheap as one for a /24.
+type Plan struct {
+	Interface *InterfaceInfo // detected interface (see DetectErr)
+	Label     string         // human-readable description of the scanned range
+
+	// DetectErr is set when interface detection failed but explicit targets
+	// allowed the plan to proceed. Interface then describes the default
+	// route's source address, or is nil if that could not be found either.
+	DetectErr error
+
+	opts    ScanOptions
+	sources []hostSource
+	names   map[string]string // IP -> hostname target it came from
+	ports   map[string][]int  // IP -> the only TCP ports to check on it
+
+	// broadcast pings the interface's broadcast addresses and returns the
+	// hosts that replied; nil unless BroadcastPing is set.
+	broadcast func(ctx context.Context) []net.IP
+}
+
+// NewPlan detects the interface and enumerates the hosts to scan, without
+// sending any probes. Interface detection is only required when no explicit
+// targets are given. Probes are bound to opts.SourceIP, which must be a
+// local address; if it is unset and opts.Interface names an interface, they
+// are bound to that interface's address so they leave through it on
+// multihomed machines. With opts.VLAN, the interface is the VLAN interface
+// on opts.Interface (or on any interface, if unset).
+func NewPlan(opts ScanOptions) (*Plan, error) {
+	opts = opts.withDefaults()
+	if opts.TwoPhase && !slices.Contains(newProber(opts).order, MethodICMP) {
+		return nil, fmt.Errorf("two-phase scan needs the %s probe method", MethodICMP)
+	}
+	if opts.VLAN != 0 {
+		name, err := vlanInterface(opts.Interface, opts.VLAN)
+		if err != nil {
+			return nil, err
+		}
+		opts.Interface = name
+	}
+	info, err := DetectInterface(opts.Interface, opts.IncludeVirtual)
+	if err != nil && len(opts.Targets) == 0 {
+		return nil, err
+	}
+	if opts.SourceIP != nil {
+		if err := checkLocalAddr(opts.SourceIP); err != nil {
+			return nil, err
+		}
+	} else if opts.Interface != "" && info != nil {
+		opts.SourceIP = info.IP
+	}
+
+	plan := &Plan{Interface: info, DetectErr: err, opts: opts, names: make(map[string]string)}
+	if err != nil {
+		plan.Interface, _ = DefaultRouteInterface()
+	} else if opts.BroadcastPing {
+		plan.broadcast = func(ctx context.Context) []net.IP {
+			return broadcastPing(ctx, info, opts.Timeout)
+		}
+	}
+	if len(opts.Targets) > 0 {
+		sources, names, ports, err := resolveTargetSources(opts.Targets)
+		if err != nil {
+			return nil, err
+		}
+		plan.sources, plan.names, plan.ports = sources, names, ports
+		plan.Label = strings.Join(opts.Targets, ",")
+		if plan.Count() == 0 {
+			return nil, fmt.Errorf("no hosts in targets %s", plan.Label)
+		}
+		return plan, nil
+	}
+
+	plan.Label = info.CIDRs()
+	plan.sources = info.hostSources()
+	if plan.Count() == 0 {
+		return nil, fmt.Errorf("no hosts in network %s: %s", plan.Label, emptyNetworkHint(info.Networks))
+	}
+	return plan, nil
+}
+
+// checkLocalAddr returns an error unless ip is assigned to this host.
+func checkLocalAddr(ip net.IP) error {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return fmt.Errorf("list local addresses: %w", err)
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source address %s is not assigned to this host", ip)
+}
+
+// Count returns the number of distinct hosts in the plan.
+func (p *Plan) Count() int {
+	return countHosts(p.sources)
+}
+
+// Workers returns the number of concurrent workers Run will use.
+func (p *Plan) Workers() int {
+	if p.opts.Workers > 0 {
+		return p.opts.Workers
+	}
+	return AutoWorkers(p.Count())
+}
+
+// ScanMeta records how a scan was performed, so that results can be
+// compared over time or across machines.
+type ScanMeta struct {
+	Interface string   `json:"interface,omitempty"`
+	Mode      string   `json:"mode,omitempty"` // "passive" when no probes were sent, "discovery" when no ports were collected, "two-phase" when only pinged hosts were port-probed
+	VLAN      int      `json:"vlan,omitempty"`
+	LocalIP   string   `json:"local_ip,omitempty"`
+	Range     string   `json:"range"`
+	Targets   []string `json:"targets,omitempty"`
+	Hosts     int      `json:"hosts"`
+	Workers   int      `json:"workers"`
+	TimeoutMS int64    `json:"timeout_ms"`
+	TCPMode   string   `json:"tcp_mode"`
+	SourceIP  string   `json:"source_ip,omitempty"`
+	Methods   []string `json:"methods"`
+	TCPPorts  []int    `json:"tcp_ports"`
+	UDPPorts  []int    `json:"udp_ports"`
+}
+
+// Meta describes the scan configuration of the plan.
+func (p *Plan) Meta() ScanMeta {
+	pr := newProber(p.opts)
+	meta := ScanMeta{
+		Range:     p.Label,
+		VLAN:      p.opts.VLAN,
+		Targets:   p.opts.Targets,
+		Hosts:     p.Count(),
+		Workers:   p.Workers(),
+		TimeoutMS: p.opts.Timeout.Milliseconds(),
+		TCPMode:   p.opts.TCPMode,
+		Methods:   pr.order,
+		TCPPorts:  pr.tcpPorts,
+		UDPPorts:  pr.udpPorts,
+	}
+	if p.opts.SourceIP != nil {
+		meta.SourceIP = p.opts.SourceIP.String()
+	}
+	if p.opts.DiscoveryOnly {
+		meta.Mode = "discovery"
+	}
+	if p.opts.TwoPhase {
+		meta.Mode = "two-phase"
+	}
+	if p.opts.Passive {
+		meta.Mode = "passive"
+		meta.Methods = []string{"arp"}
+		meta.TCPPorts, meta.UDPPorts = []int{}, []int{}
+	}
+	if p.opts.Listen > 0 {
+		meta.Methods = append(slices.Clip(meta.Methods), "mdns", "ssdp")
+	}
+	if p.Interface != nil {
+		meta.Interface = p.Interface.Name
+		meta.LocalIP = p.Interface.IP.String()
+	}
+	return meta
+}
+
+// Hosts returns every host in the plan, in scan order, as a single list.
+// This materializes the whole range; Run does not need it.
+func (p *Plan) Hosts() []net.IP {
+	var hosts []net.IP
+	for ip := range interleave(p.sources) {
+		hosts = append(hosts, ip)
+	}
+	return hosts
+}
+
+// TargetName returns the hostname target that ip was resolved from, if any.
+func (p *Plan) TargetName(ip net.IP) string {
+	return p.names[ip.String()]
+}
+
+// Report is the outcome of running a Plan.
+type Report struct {
+	Results []ScanResult // discovered hosts, enriched
+	Errors  []HostError  // notable probe errors for hosts not found, or not answering a TwoPhase port pass
+	Total   int          // number of hosts targeted
+	Scanned int          // number of hosts actually probed
+	Stats   ProbeStats   // estimate of the probe traffic sent
+
+	// Warnings lists optional steps that failed without failing the scan.
+	Warnings []string
+
+	// Checks holds the state of each port of the "host:port" targets.
+	Checks []PortCheck
+
+	// Phases times the passes of a ScanOptions.TwoPhase scan. The port
+	// pass is missing if the discovery pass found nothing or was cut short.
+	Phases []ScanPhase
+
+	// Interrupted is why the scan stopped before probing every host or
+	// finishing enrichment: the context's error, or an error wrapping
+	// ErrNetworkDown or ErrHostLimit. It is nil for a complete scan. With every host
+	// probed (Complete), only lookups such as host names were cut short.
+	Interrupted error
+}
+
+// Complete reports whether every targeted host was probed.
+func (r *Report) Complete() bool {
+	return r.Scanned >= r.Total
+}
+
+// Coverage returns the percentage of targeted hosts that were probed.
+func (r *Report) Coverage() float64 {
+	if r.Total == 0 {
+		return 100
+	}
+	return 100 * float64(min(r.Scanned, r.Total)) / float64(r.Total)
+}
+
+// Run scans the plan's hosts and enriches the results. Progress updates are
+// sent on progressCh if it is non-nil; the channel is not closed. Once ctx
+// is done, updates the channel is not ready for are dropped, so a caller
+// may stop reading after cancelling. With ScanOptions.Passive, nothing is
+// probed and no progress is sent.
+//
+// If ctx is cancelled or its deadline passes, or the network goes down and
+// does not come back, Run stops starting new probes, abandons the probes
+// and lookups in flight, cuts enrichment short, and returns the partial
+// report (with Interrupted set, and Scanned < Total if hosts were left)
+// rather than an error.
+func (p *Plan) Run(ctx context.Context, progressCh chan<- Progress) (*Report, error) {
+	if p.opts.Passive {
+		return p.passive(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var warnings []string
+	listened := p.listen(ctx)
+	var (
+		results  []ScanResult
+		hostErrs []HostError
+		scanned  int
+		stats    ProbeStats
+		phases   []ScanPhase
+		downErr  error
+	)
+	if p.opts.TwoPhase {
+		results, hostErrs, scanned, stats, phases, downErr = p.twoPhase(ctx, p.probeOptions(), p.echoed(ctx), progressCh)
+	} else {
+		results, hostErrs, scanned, stats, downErr = scanSources(ctx, p.sources, p.probeOptions(), p.echoed(ctx), progressCh, nil)
+	}
+	if downErr != nil && !errors.Is(downErr, ErrHostLimit) {
+		cancel() // the network is gone; skip the lookups
+	}
+	heard, err := listened()
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("listen: %v", err))
+	}
+	results = p.addHeard(results, heard)
+
+	for i := range results {
+		results[i].Target = p.names[results[i].IP.String()]
+	}
+	// The router is wanted even when it is off-range or ignores probes
+	if gw, err := DefaultGateway(); err == nil && ctx.Err() == nil {
+		results = markGateway(ctx, results, gw, p.opts, len(p.opts.Targets) == 0)
+	}
+	Enrich(ctx, results, EnrichOptions{
+		SkipDNS:     p.opts.SkipDNS,
+		DNSTimeout:  p.opts.DNSTimeout,
+		SkipVendor:  p.opts.SkipVendor,
+		HTTP:        p.opts.HTTP,
+		Services:    p.opts.MDNSServices,
+		Labels:      p.opts.Labels,
+		DeviceRules: p.opts.DeviceRules,
+		Vendors:     p.opts.Vendors,
+		SNMP:        p.opts.SNMPCommunities,
+		SNMPVersion: p.opts.SNMPVersion,
+		ReadLimit:   p.opts.ReadLimit,
+		SourceIP:    p.opts.SourceIP,
+		Timeout:     2 * p.opts.Timeout,
+	})
+	applyHeard(results, heard)
+	if ctx.Err() == nil {
+		joinNeighbors6(results, GetNeighbors6())
+	}
+	if p.opts.RouterHostnames && !p.opts.SkipDNS && ctx.Err() == nil {
+		if err := fillRouterHostnames(ctx, results, p.opts.Router, 4*p.opts.Timeout); err != nil {
+			warnings = append(warnings, fmt.Sprintf("router hostnames: %v", err))
+		}
+	}
+	if p.opts.IncludeSelf && len(p.opts.Targets) == 0 {
+		self := p.Interface.SelfResult()
+		applyLabel(&self, p.opts.Labels)
+		results = append(results, self)
+	}
+	for i := range results {
+		p.hook(&results[i])
+	}
+	SortResults(results)
+
+	report := &Report{Results: results, Errors: hostErrs, Total: p.Count(), Scanned: scanned, Stats: stats, Warnings: warnings}
+	report.Checks = p.portChecks(results)
+	report.Phases = phases
+	report.Interrupted = interruption(report, downErr, ctx)
+	return report, nil
+}
+
+// hook calls ScanOptions.EnrichHook with r, once r is final.
+func (p *Plan) hook(r *ScanResult) {
+	if p.opts.EnrichHook != nil {
+		p.opts.EnrichHook(r)
+	}
+}
+
+// listen starts listening for announcements if ScanOptions.Listen is set.
+// The returned function waits for the listener and returns what was heard;
+// it returns nothing if Listen is not set.
+func (p *Plan) listen(ctx context.Context) func() (map[string]heardHost, error) {
+	if p.opts.Listen <= 0 {
+		return func() (map[string]heardHost, error) { return nil, nil }
+	}
+	type heardResult struct {
+		heard map[string]heardHost
+		err   error
+	}
+	done := make(chan heardResult, 1)
+	go func() {
+		heard, err := listenAnnouncements(ctx, p.listenInterface(), p.opts.Listen)
+		done <- heardResult{heard, err}
+	}()
+	return func() (map[string]heardHost, error) {
+		r := <-done
+		return r.heard, r.err
+	}
+}
+
+// probeOptions returns the scan options for the sweep, with the ports of
+// "host:port" targets.
+func (p *Plan) probeOptions() ScanOptions {
+	opts := p.opts
+	opts.hostPorts = p.ports
+	return opts
+}
+
+// echoed returns the hosts that answered a broadcast ping, or nil if
+// BroadcastPing is not set. The ping gives up once ctx is done.
+func (p *Plan) echoed(ctx context.Context) map[string]bool {
+	if p.broadcast == nil {
+		return nil
+	}
+	echoed := make(map[string]bool)
+	for _, ip := range p.broadcast(ctx) {
+		echoed[ip.String()] = true
+	}
+	return echoed
+}
+
+// interruption returns why a scan stopped early: the network went down, or
+// ctx ended before every host was probed or while the results were being
+// enriched.
+func interruption(r *Report, downErr error, ctx context.Context) error {
+	switch {
+	case downErr != nil:
+		return downErr
+	case !r.Complete() || ctx.Err() != nil:
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// Stream scans the plan's hosts like Run, but enriches each host as soon as
+// it is found and passes it to emit instead of collecting the results, so
+// memory use does not grow with the number of hosts found. Results arrive
+// in discovery order, not sorted, and emit is never called concurrently.
+// The gateway is only marked if it is within the scanned hosts, router
+// hostnames and IPv6 addresses are not filled in, ScanOptions.Listen is
+// ignored, and probe errors are not collected; the returned report has no
+// Results, Errors or Checks. If emit fails, the scan is stopped and its first
+// error returned. Interruptions are reported as by Run.
+func (p *Plan) Stream(ctx context.Context, progressCh chan<- Progress, emit func(ScanResult) error) (*Report, error) {
+	if p.opts.Passive {
+		return nil, fmt.Errorf("passive mode cannot stream results")
+	}
+	if p.opts.TwoPhase {
+		return nil, fmt.Errorf("two-phase scans cannot stream results")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	gw, _ := DefaultGateway()
+	opts := EnrichOptions{
+		SkipDNS:     p.opts.SkipDNS,
+		DNSTimeout:  p.opts.DNSTimeout,
+		SkipVendor:  p.opts.SkipVendor,
+		HTTP:        p.opts.HTTP,
+		Services:    p.opts.MDNSServices,
+		Labels:      p.opts.Labels,
+		DeviceRules: p.opts.DeviceRules,
+		Vendors:     p.opts.Vendors,
+		SNMP:        p.opts.SNMPCommunities,
+		SNMPVersion: p.opts.SNMPVersion,
+		ReadLimit:   p.opts.ReadLimit,
+		SourceIP:    p.opts.SourceIP,
+		Timeout:     2 * p.opts.Timeout,
+	}
+	arp := &arpCache{}
+	addrs, _ := net.InterfaceAddrs()
+
+	var (
+		mu      sync.Mutex
+		emitErr error
+	)
+	send := func(r ScanResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if emitErr != nil {
+			return
+		}
+		if emitErr = emit(r); emitErr != nil {
+			cancel()
+		}
+	}
+
+	_, _, scanned, stats, scanErr := scanSources(ctx, p.sources, p.probeOptions(), p.echoed(ctx), progressCh, func(r ScanResult) {
+		r.Target = p.names[r.IP.String()]
+		if r.IP.Equal(gw) {
+			r.Role = "gateway"
+		}
+		o := opts
+		if ctx.Err() != nil {
+			o.SkipDNS, o.HTTP, o.Services, o.SNMP = true, false, false, nil
+		}
+		enrichOne(ctx, &r, arp.table(r.IP.String()), addrs, o)
+		p.hook(&r)
+		send(r)
+	})
+
+	if p.opts.IncludeSelf && len(p.opts.Targets) == 0 {
+		self := p.Interface.SelfResult()
+		applyLabel(&self, p.opts.Labels)
+		p.hook(&self)
+		send(self)
+	}
+	if emitErr != nil {
+		return nil, emitErr
+	}
+	report := &Report{Total: p.Count(), Scanned: scanned, Stats: stats}
+	report.Interrupted = interruption(report, scanErr, ctx)
+	return report, nil
+}
+
+// Discover enumerates, scans, and enriches hosts according to opts and
+// returns the fully populated results sorted by IP. It writes nothing to
+// stdout or stderr. If the scan is cut short (ctx ends or the network goes
+// down), the partial results are returned together with the reason.
+func Discover(ctx context.Context, opts ScanOptions) ([]ScanResult, error) {
+	plan, err := NewPlan(opts)
+	if err != nil {
+		return nil, err
+	}
+	report, err := plan.Run(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return report.Results, report.Interrupted
+}
+
+// SortResults sorts results by IPv4 address.
+func SortResults(results []ScanResult) {
+	sort.Slice(results, func(i, j int) bool {
+		return ipToUint32(results[i].IP) < ipToUint32(results[j].IP)
+	})
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	ip = ip.To4()
+	if ip == nil {
+		return 0
+	}
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestInterruption(t *testing.T) {
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+	downErr := errors.New("network down")
+
+	tests := []struct {
+		name    string
+		scanned int
+		downErr error
+		ctx     context.Context
+		want    error
+	}{
+		{"complete", 4, nil, context.Background(), nil},
+		{"network down", 2, downErr, done, downErr},
+		{"cut short while probing", 2, nil, done, context.Canceled},
+		// Every host was probed, but ctx ended during enrichment
+		{"cut short while enriching", 4, nil, done, context.Canceled},
+	}
+	for _, tt := range tests {
+		r := &Report{Total: 4, Scanned: tt.scanned}
+		if got := interruption(r, tt.downErr, tt.ctx); !errors.Is(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("%s: interruption = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestRunHookSeesFinalResults checks that EnrichHook runs once per result,
+// after enrichment, and that what it sets is kept.
+func TestRunHookSeesFinalResults(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go acceptAndClose(ln)
+
+	calls := make(map[string]int)
+	plan, err := NewPlan(ScanOptions{
+		Targets:     []string{ln.Addr().String()},
+		Timeout:     time.Second,
+		MethodOrder: []string{MethodTCP},
+		SkipDNS:     true,
+		EnrichHook: func(r *ScanResult) {
+			calls[r.IP.String()]++
+			if r.Hostname != "-" || r.Confidence == 0 {
+				t.Errorf("hook called before enrichment: %+v", *r)
+			}
+			r.Extra = map[string]string{"asset": "A1"}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := plan.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) == 0 {
+		t.Fatal("no results")
+	}
+	for _, r := range report.Results {
+		if n := calls[r.IP.String()]; n != 1 {
+			t.Errorf("hook called %d times for %s, want 1", n, r.IP)
+		}
+		if r.Extra["asset"] != "A1" {
+			t.Errorf("%s: Extra = %v, want the hook's fields", r.IP, r.Extra)
+		}
+	}
+}
+
+// TestRunMaxHosts checks that a scan stops at MaxHosts, reports no more
+// hosts than that, and says why it stopped.
+func TestRunMaxHosts(t *testing.T) {
+	plan, err := NewPlan(ScanOptions{
+		Targets:     []string{"127.0.0.1-127.0.0.64"},
+		Workers:     16,
+		Timeout:     time.Second,
+		MethodOrder: []string{MethodTCP},
+		TCPPorts:    []int{freePort(t)}, // refused, so every host answers
+		SkipDNS:     true,
+		MaxHosts:    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := plan.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 3 {
+		t.Errorf("got %d results, want 3", len(report.Results))
+	}
+	if !errors.Is(report.Interrupted, ErrHostLimit) || report.Complete() {
+		t.Errorf("Interrupted = %v, Scanned %d/%d; want the host limit with hosts left", report.Interrupted, report.Scanned, report.Total)
+	}
+}
@@ -0,0 +1,29 @@
+// Package scanner discovers devices on a local IPv4 network.
+//
+// The stable public API for embedding localscan is:
+//
+//   - Discover: enumerate, scan, and enrich in one call.
+//   - NewPlan, Plan.Run, Report: the same steps split so callers can inspect
+//     the host list before probing and receive Progress updates.
+//   - ScanOptions, ScanResult, Progress, HostError: the types used above.
+//   - Enrich, EnrichOptions: hostname/MAC/vendor enrichment for results
+//     obtained some other way.
+//   - ScanOptions.EnrichHook and EnrichOptions.Hook: custom per-host
+//     enrichment, such as inventory lookups stored in ScanResult.Extra.
+//   - ResolveTargets, DetectInterface, LookupVendor, ResolveHostname: helpers
+//     usable on their own.
+//
+// Nothing in this package writes to stdout or stderr, and it does not
+// depend on the CLI. Other exported functions (Scan, ScanGroups and their
+// Context variants, history helpers) are used by the localscan CLI and may
+// change between releases.
+//
+// Version follows semantic versioning for the API above: minor releases
+// only add to it, such as new ScanResult or ScanOptions fields whose zero
+// value keeps the old behavior, so build these types with keyed fields.
+// Removing or changing anything in it needs a new major version.
+package scanner
+
+// Version is the release version of localscan and of this package's
+// stable API.
+const Version = "1.0.0"
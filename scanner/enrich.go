@@ -0,0 +1,242 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultEnrichWorkers bounds concurrent lookups during enrichment.
+const defaultEnrichWorkers = 20
+
+// servicesBudget bounds Bonjour service enumeration per host.
+const servicesBudget = 3 * time.Second
+
+// EnrichOptions controls which lookups Enrich performs.
+type EnrichOptions struct {
+	SkipDNS     bool              // do not resolve hostnames (Hostname is "-")
+	DNSTimeout  time.Duration     // per-host reverse lookup timeout (DefaultDNSTimeout if 0)
+	SkipVendor  bool              // do not look up MAC vendors (Vendor is "-")
+	HTTP        bool              // probe HTTP on port 80 for redirects / captive portals
+	Services    bool              // enumerate Bonjour services over mDNS
+	Labels      map[string]string // normalized MAC -> friendly name
+	DeviceRules []DeviceRule      // device type rules tried before the built-in ones
+	Vendors     map[string]string // MAC prefix -> vendor, over the built-in table
+	SNMP        []string          // SNMP communities to try for each host (none if empty)
+	SNMPVersion string            // SNMP version for SNMP (SNMPv1 if empty)
+	ReadLimit   int               // SNMP reply buffer size (DefaultReadLimit if 0)
+	SourceIP    net.IP            // local address SNMP requests are sent from (OS default if nil)
+	Timeout     time.Duration     // HTTP probe and ARP retry timeout (2*DefaultTimeout if 0)
+	Workers     int               // concurrent lookups (20 if 0)
+
+	// Hook, if set, is called with each result after the built-in
+	// enrichment, to add custom fields in ScanResult.Extra (CMDB tags,
+	// asset numbers, ...) or adjust the others. It is called concurrently
+	// from the lookup workers, so it must be safe for concurrent use.
+	// Plan.Run and Plan.Stream do not set it; they call
+	// ScanOptions.EnrichHook once each result is final instead.
+	Hook func(*ScanResult)
+}
+
+// Enrich fills in Hostname, MAC, Vendor, RandomMAC, DeviceType,
+// Reachability, Confidence, the AirPlay name and model of Apple devices
+// and, when enabled, Bonjour services, SNMP communities, HTTP redirect
+// details and Role for each result. Lookups run concurrently.
+// On-link hosts missing from the ARP table are nudged into it first (see
+// resolveMissingMACs). Once ctx is done, remaining results only get the
+// cheap ARP-based fields.
+func Enrich(ctx context.Context, results []ScanResult, opts EnrichOptions) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * DefaultTimeout
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultEnrichWorkers
+	}
+
+	addrs, _ := net.InterfaceAddrs() // once for all hosts; nil skips reachability
+	arpTable := resolveMissingMACs(ctx, results, GetARPTable(), addrs, opts.Timeout)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				o := opts
+				if ctx.Err() != nil {
+					o.SkipDNS, o.HTTP, o.Services, o.SNMP = true, false, false, nil
+				}
+				enrichOne(ctx, &results[i], arpTable, addrs, o)
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// enrichOne enriches r. Lookups in flight when ctx is done give up.
+// Reachability is judged against the local interface addresses addrs, and
+// left unset if addrs is nil.
+func enrichOne(ctx context.Context, r *ScanResult, arpTable map[string]string, addrs []net.Addr, opts EnrichOptions) {
+	ipStr := r.IP.String()
+
+	r.Hostname = "-"
+	if !opts.SkipDNS {
+		timeout := opts.DNSTimeout
+		if timeout <= 0 {
+			timeout = DefaultDNSTimeout
+		}
+		r.Hostname = resolveHostname(ctx, ipStr, timeout)
+	}
+
+	r.MAC, r.Vendor = "-", "-"
+	if mac, ok := arpTable[ipStr]; ok {
+		r.MAC = mac
+		r.RandomMAC = IsRandomizedMAC(mac)
+		if !opts.SkipVendor {
+			r.Vendor = lookupVendor(mac, opts.Vendors)
+		}
+	}
+	applyLabel(r, opts.Labels)
+	r.DeviceType = classifyDevice(*r, opts.DeviceRules)
+
+	if !opts.SkipDNS && isAppleCandidate(r.OpenPorts) {
+		r.DeviceName, r.Model = lookupAppleDevice(ctx, ipStr, opts.Timeout)
+		if r.Hostname == "-" && r.DeviceName != "" {
+			r.Hostname = r.DeviceName
+		}
+	}
+
+	if opts.Services {
+		r.Services, r.Instances = browseServices(ctx, ipStr, servicesBudget)
+	}
+
+	if len(opts.SNMP) > 0 && r.SNMP == nil {
+		r.SNMP = probeSNMP(ctx, ipStr, opts.SNMP, opts.SNMPVersion, opts.Timeout, opts.ReadLimit, opts.SourceIP)
+	}
+
+	if opts.HTTP && containsPort(r.OpenPorts, 80) {
+		if hi, err := probeHTTP(ctx, ipStr, opts.Timeout); err == nil {
+			r.Redirect = hi.RedirectHost
+			if hi.CaptivePortal && r.Role == "" {
+				r.Role = "captive-portal-ish"
+			}
+		}
+	}
+
+	if addrs != nil {
+		r.Reachability = reachability(*r, addrs)
+	}
+	r.Confidence = scoreConfidence(*r)
+	if opts.Hook != nil {
+		opts.Hook(r)
+	}
+}
+
+// arpRetryInterval is how often the ARP table is re-read while waiting for
+// nudged hosts to resolve.
+const arpRetryInterval = 100 * time.Millisecond
+
+// resolveMissingMACs makes the OS resolve found hosts that are on a local
+// network but missing from arpTable, since a host found by ICMP, TCP or
+// UDP can still lack an entry when the table was read. Each is sent one
+// UDP datagram to the discard port, which needs its MAC, and the table is
+// re-read until all of them appear, timeout passes or ctx is done. Entries
+// are added to arpTable, which is returned. addrs are the local interface
+// addresses; nothing is resolved if it is nil.
+func resolveMissingMACs(ctx context.Context, results []ScanResult, arpTable map[string]string, addrs []net.Addr, timeout time.Duration) map[string]string {
+	if ctx.Err() != nil || addrs == nil {
+		return arpTable
+	}
+	var missing []string
+	for _, r := range results {
+		ip := r.IP.String()
+		if _, ok := arpTable[ip]; !ok && r.Method != "SELF" && onLink(r.IP, addrs) {
+			missing = append(missing, ip)
+		}
+	}
+	if len(missing) == 0 {
+		return arpTable
+	}
+
+	for _, ip := range missing {
+		if conn, err := net.Dial("udp", net.JoinHostPort(ip, "9")); err == nil {
+			conn.Write([]byte{0})
+			conn.Close()
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	for len(missing) > 0 && time.Now().Before(deadline) {
+		select {
+		case <-time.After(arpRetryInterval):
+		case <-ctx.Done():
+			return arpTable
+		}
+		for ip, mac := range GetARPTable() {
+			if _, ok := arpTable[ip]; !ok {
+				arpTable[ip] = mac
+			}
+		}
+		missing = slices.DeleteFunc(missing, func(ip string) bool {
+			_, ok := arpTable[ip]
+			return ok
+		})
+	}
+	return arpTable
+}
+
+// onLink reports whether ip is in one of the IPv4 networks of addrs
+// without being one of the addresses itself, so the OS resolves it by ARP.
+func onLink(ip net.IP, addrs []net.Addr) bool {
+	if ip.To4() == nil || ip.IsLoopback() {
+		return false
+	}
+	found := false
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok || n.IP.To4() == nil || n.IP.IsLoopback() {
+			continue
+		}
+		if n.IP.Equal(ip) {
+			return false
+		}
+		found = found || n.Contains(ip)
+	}
+	return found
+}
+
+// arpCacheAge is how old the cached ARP table may be before a lookup of an
+// IP that is not in it re-reads the table.
+const arpCacheAge = 250 * time.Millisecond
+
+// arpCache shares ARP table reads between hosts enriched one at a time, as
+// they are found. A fresh table replaces the old one, so a returned map is
+// never modified and may be read without locking.
+type arpCache struct {
+	mu   sync.Mutex
+	read time.Time
+	arp  map[string]string
+}
+
+// table returns an ARP table that contains ip if the OS has resolved it,
+// re-reading the table unless it was read very recently.
+func (c *arpCache) table(ip string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.arp[ip]; !ok && time.Since(c.read) >= arpCacheAge {
+		c.arp, c.read = GetARPTable(), time.Now()
+	}
+	return c.arp
+}
+
+// applyLabel sets r.Label when r's MAC has a user-assigned name.
+func applyLabel(r *ScanResult, labels map[string]string) {
+	if key, ok := labelKey(r.MAC); ok {
+		r.Label = labels[key]
+	}
+}
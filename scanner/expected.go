@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// ExpectedDevice is an entry of the expected-devices allowlist. A device is
+// identified by its MAC when both sides have one, and by its IP otherwise.
+type ExpectedDevice struct {
+	MAC  string `json:"mac,omitempty"`
+	IP   string `json:"ip,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// ExpectedPath returns the default allowlist path, ~/.localscan/expected.json.
+func ExpectedPath() string {
+	return filepath.Join(DataDir(), "expected.json")
+}
+
+// LoadExpected reads a JSON array of expected devices from path. MACs are
+// normalized; every entry needs a valid MAC, IP, or both.
+func LoadExpected(path string) ([]ExpectedDevice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var devices []ExpectedDevice
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, d := range devices {
+		if d.MAC == "" && d.IP == "" {
+			return nil, fmt.Errorf("%s: entry %d has neither mac nor ip", path, i+1)
+		}
+		if d.MAC != "" {
+			mac, ok := labelKey(d.MAC)
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid mac %q", path, d.MAC)
+			}
+			devices[i].MAC = mac
+		}
+		if d.IP != "" && net.ParseIP(d.IP).To4() == nil {
+			return nil, fmt.Errorf("%s: invalid ip %q", path, d.IP)
+		}
+	}
+	return devices, nil
+}
+
+// matches reports whether r is the expected device d.
+func (d ExpectedDevice) matches(r ScanResult) bool {
+	if mac, ok := labelKey(r.MAC); ok && d.MAC != "" {
+		return mac == d.MAC
+	}
+	return d.IP != "" && d.IP == r.IP.String()
+}
+
+// CheckExpected compares results against the allowlist. Present hosts that
+// are not expected are marked Unexpected, keeping any diff Status, and
+// expected devices that were not found are appended with Status "MISSING".
+// A GONE entry from a diff that belongs to an expected device becomes its
+// MISSING entry.
+func CheckExpected(results []ScanResult, expected []ExpectedDevice) []ScanResult {
+	found := make([]bool, len(expected))
+	var gone []ScanResult
+	kept := results[:0]
+	for _, r := range results {
+		if r.Status == "GONE" {
+			gone = append(gone, r)
+			continue
+		}
+		known := false
+		for i, d := range expected {
+			if d.matches(r) {
+				found[i], known = true, true
+			}
+		}
+		if !known {
+			r.Unexpected = true
+		}
+		kept = append(kept, r)
+	}
+
+	for i, d := range expected {
+		if found[i] {
+			continue
+		}
+		missing := ScanResult{Hostname: "-", MAC: "-", Vendor: "-", Method: "-"}
+		for j, g := range gone {
+			if d.matches(g) {
+				missing = g
+				gone = append(gone[:j], gone[j+1:]...)
+				break
+			}
+		}
+		if missing.IP == nil {
+			missing.IP = net.ParseIP(d.IP).To4()
+		}
+		if d.MAC != "" && missing.MAC == "-" {
+			missing.MAC = d.MAC
+		}
+		if d.Name != "" && missing.Label == "" {
+			missing.Label = d.Name
+		}
+		missing.Status = "MISSING"
+		kept = append(kept, missing)
+	}
+	return append(kept, gone...)
+}
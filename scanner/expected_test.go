@@ -0,0 +1,29 @@
+package scanner
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCheckExpectedKeepsDiffStatus(t *testing.T) {
+	results := []ScanResult{
+		{IP: net.ParseIP("192.168.1.10").To4(), MAC: "aa:bb:cc:dd:ee:ff"},
+		{IP: net.ParseIP("192.168.1.20").To4(), MAC: "11:22:33:44:55:66", Status: "NEW"},
+		{IP: net.ParseIP("192.168.1.30").To4(), MAC: "22:33:44:55:66:77", Status: "CHANGED"},
+	}
+	expected := []ExpectedDevice{{IP: "192.168.1.10"}}
+
+	got := CheckExpected(results, expected)
+	if len(got) != 3 {
+		t.Fatalf("CheckExpected returned %d results, want 3", len(got))
+	}
+	want := []struct {
+		status     string
+		unexpected bool
+	}{{"", false}, {"NEW", true}, {"CHANGED", true}}
+	for i, w := range want {
+		if got[i].Status != w.status || got[i].Unexpected != w.unexpected {
+			t.Errorf("%s: Status %q Unexpected %v, want %q %v", got[i].IP, got[i].Status, got[i].Unexpected, w.status, w.unexpected)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// DefaultGateway returns the IPv4 address of the default gateway.
+// It reads /proc/net/route on Linux and the routing table printed by
+// netstat/route elsewhere.
+func DefaultGateway() (net.IP, error) {
+	if runtime.GOOS == "linux" {
+		data, err := os.ReadFile("/proc/net/route")
+		if err != nil {
+			return nil, fmt.Errorf("read routing table: %w", err)
+		}
+		if gw := parseProcRoute(string(data)); gw != nil {
+			return gw, nil
+		}
+		return nil, fmt.Errorf("no default route")
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("route", "print", "-4")
+	default:
+		cmd = exec.Command("netstat", "-rn", "-f", "inet")
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("read routing table: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if gw := parseRouteLine(line); gw != nil {
+			return gw, nil
+		}
+	}
+	return nil, fmt.Errorf("no default route")
+}
+
+// parseProcRoute finds the default route's gateway in /proc/net/route, where
+// addresses are little-endian hex.
+func parseProcRoute(data string) net.IP {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		if !ip.IsUnspecified() {
+			return ip
+		}
+	}
+	return nil
+}
+
+// parseRouteLine extracts the gateway from a default route line.
+// Handles both macOS/BSD netstat (`default  192.168.1.1  UGScg  en0`)
+// and Windows route print (`0.0.0.0  0.0.0.0  192.168.1.1  192.168.1.20  25`).
+func parseRouteLine(line string) net.IP {
+	fields := strings.Fields(line)
+	var gw string
+	switch {
+	case len(fields) >= 2 && fields[0] == "default":
+		gw = fields[1]
+	case len(fields) >= 3 && fields[0] == "0.0.0.0" && fields[1] == "0.0.0.0":
+		gw = fields[2]
+	default:
+		return nil
+	}
+	ip := net.ParseIP(gw).To4()
+	if ip == nil || ip.IsUnspecified() {
+		return nil
+	}
+	return ip
+}
+
+// probeGateway probes the gateway directly, for when it lies outside the
+// scanned range. A gateway that ignores every probe is still reported if the
+// probes left it in the ARP table.
+func probeGateway(ctx context.Context, gw net.IP, opts ScanOptions) (ScanResult, bool) {
+	pr := newProber(opts.withDefaults())
+	method, ports, _ := pr.detectHost(ctx, gw.String())
+	if method == "" {
+		if _, ok := GetARPTable()[gw.String()]; !ok {
+			return ScanResult{}, false
+		}
+		method = "ARP"
+	}
+	r := ScanResult{IP: gw, Method: method, OpenPorts: ports.open, RTT: ports.rtt, Answers: ports.answers}
+	if opts.RecordClosed {
+		r.ClosedPorts = ports.closed
+	}
+	return r, true
+}
+
+// markGateway sets the "gateway" role on gw's result, adding the result via
+// a direct probe if includeMissing is set and the scan did not find it.
+func markGateway(ctx context.Context, results []ScanResult, gw net.IP, opts ScanOptions, includeMissing bool) []ScanResult {
+	for i := range results {
+		if results[i].IP.Equal(gw) {
+			if results[i].Role == "" {
+				results[i].Role = "gateway"
+			}
+			return results
+		}
+	}
+	if !includeMissing {
+		return results
+	}
+	if r, ok := probeGateway(ctx, gw, opts); ok {
+		r.Role = "gateway"
+		results = append(results, r)
+	}
+	return results
+}
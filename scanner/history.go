@@ -1,12 +1,28 @@
 package scanner
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
+// HistoryVersion is the current format version of the history file.
+const HistoryVersion = 1
+
+// historyFile is the versioned on-disk layout of last.json.
+type historyFile struct {
+	Version int            `json:"version"`
+	Hosts   []historyEntry `json:"hosts"`
+}
+
 // historyEntry is the JSON-serializable form of a scan result.
 type historyEntry struct {
 	IP        string `json:"ip"`
@@ -15,17 +31,125 @@ type historyEntry struct {
 	Vendor    string `json:"vendor"`
 	Method    string `json:"method"`
 	OpenPorts []int  `json:"open_ports"`
+	Role      string `json:"role,omitempty"`
 }
 
-func historyPath() string {
+// dataDir resolves the data directory once per run.
+var dataDir = sync.OnceValues(resolveDataDir)
+
+// DataDir returns the directory where localscan keeps its state and config:
+// $LOCALSCAN_HOME if set, otherwise ~/.localscan if it already exists or
+// XDG_DATA_HOME is unset, otherwise $XDG_DATA_HOME/localscan. Without a
+// home directory it falls back to ./.localscan; DataDirErr reports why.
+func DataDir() string {
+	dir, _ := dataDir()
+	return dir
+}
+
+// DataDirErr returns the reason DataDir fell back to the current
+// directory, or nil if it did not.
+func DataDirErr() error {
+	_, err := dataDir()
+	return err
+}
+
+func resolveDataDir() (string, error) {
+	if dir := os.Getenv("LOCALSCAN_HOME"); dir != "" {
+		return dir, nil
+	}
+	xdg := os.Getenv("XDG_DATA_HOME")
 	home, err := os.UserHomeDir()
+	if err == nil {
+		dir := filepath.Join(home, ".localscan")
+		if _, statErr := os.Stat(dir); statErr == nil || xdg == "" {
+			return dir, nil
+		}
+	}
+	if xdg != "" {
+		return filepath.Join(xdg, "localscan"), nil
+	}
+	return ".localscan", fmt.Errorf("no home directory (%v); set LOCALSCAN_HOME or XDG_DATA_HOME", err)
+}
+
+func historyPath() string {
+	return filepath.Join(DataDir(), "last.json")
+}
+
+func historyLogPath() string {
+	return filepath.Join(DataDir(), "history.jsonl")
+}
+
+// HistoryFiles returns the stored history files that exist: the last scan
+// and its backup, the history log, the seen times and the probe cache.
+// Other files in the data directory, such as the config, are not included.
+func HistoryFiles() []string {
+	var files []string
+	for _, p := range []string{historyPath(), backupPath(historyPath()), historyLogPath(), seenPath(), probeCachePath()} {
+		if _, err := os.Stat(p); err == nil {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// toHistoryEntry converts a scan result to its history form.
+func toHistoryEntry(r ScanResult) historyEntry {
+	ports := r.OpenPorts
+	if ports == nil {
+		ports = []int{}
+	}
+	return historyEntry{
+		IP:        r.IP.String(),
+		Hostname:  r.Hostname,
+		MAC:       r.MAC,
+		Vendor:    r.Vendor,
+		Method:    r.Method,
+		OpenPorts: ports,
+		Role:      r.Role,
+	}
+}
+
+// backupPath returns the path the previous version of p is kept at.
+func backupPath(p string) string {
+	return p + ".bak"
+}
+
+// writeFileAtomic writes data to a temporary file next to p and renames it
+// into place, so readers never see a partial file. With backup set, the
+// current p is kept as p.bak right before the rename.
+func writeFileAtomic(p string, data []byte, backup bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".tmp*")
 	if err != nil {
-		home = "."
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
-	return filepath.Join(home, ".localscan", "last.json")
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	if backup {
+		if err := os.Rename(p, backupPath(p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("keep backup: %w", err)
+		}
+	}
+	return os.Rename(tmp.Name(), p)
 }
 
 // SaveHistory writes the current scan results to ~/.localscan/last.json.
+// The file is replaced atomically and the previous one is kept as
+// last.json.bak.
 func SaveHistory(results []ScanResult) error {
 	p := historyPath()
 	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
@@ -34,37 +158,66 @@ func SaveHistory(results []ScanResult) error {
 
 	entries := make([]historyEntry, len(results))
 	for i, r := range results {
-		ports := r.OpenPorts
-		if ports == nil {
-			ports = []int{}
-		}
-		entries[i] = historyEntry{
-			IP:        r.IP.String(),
-			Hostname:  r.Hostname,
-			MAC:       r.MAC,
-			Vendor:    r.Vendor,
-			Method:    r.Method,
-			OpenPorts: ports,
-		}
+		entries[i] = toHistoryEntry(r)
 	}
 
-	data, err := json.MarshalIndent(entries, "", "  ")
+	data, err := json.MarshalIndent(historyFile{Version: HistoryVersion, Hosts: entries}, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(p, data, 0644)
+	return writeFileAtomic(p, data, true)
 }
 
-// LoadHistory reads the previous scan results from ~/.localscan/last.json.
-func LoadHistory() ([]ScanResult, error) {
-	data, err := os.ReadFile(historyPath())
+// HistoryLog appends scan results to ~/.localscan/history.jsonl, one JSON
+// object per line. Unlike last.json the file is never rewritten, so hosts
+// can be recorded as they are found and earlier scans are kept. Each line
+// carries the start time of its scan in "scan".
+type HistoryLog struct {
+	f    *os.File
+	enc  *json.Encoder
+	scan string
+}
+
+// historyLogLine is one line of history.jsonl.
+type historyLogLine struct {
+	Scan string `json:"scan"`
+	historyEntry
+}
+
+// OpenHistoryLog opens the history log for appending, creating it if
+// needed. start identifies the scan the appended results belong to.
+func OpenHistoryLog(start time.Time) (*HistoryLog, error) {
+	p := historyLogPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
+	return &HistoryLog{f: f, enc: json.NewEncoder(f), scan: start.Format(time.RFC3339)}, nil
+}
 
-	var entries []historyEntry
-	if err := json.Unmarshal(data, &entries); err != nil {
-		return nil, err
+// Append writes r as one line. It is not safe for concurrent use.
+func (h *HistoryLog) Append(r ScanResult) error {
+	return h.enc.Encode(historyLogLine{Scan: h.scan, historyEntry: toHistoryEntry(r)})
+}
+
+// Close closes the history log.
+func (h *HistoryLog) Close() error {
+	return h.f.Close()
+}
+
+// LoadHistory reads the previous scan results from ~/.localscan/last.json,
+// falling back to last.json.bak if the primary file is missing or corrupt.
+func LoadHistory() ([]ScanResult, error) {
+	p := historyPath()
+	entries, err := readHistoryFile(p)
+	if err != nil {
+		var backupErr error
+		if entries, backupErr = readHistoryFile(backupPath(p)); backupErr != nil {
+			return nil, err
+		}
 	}
 
 	results := make([]ScanResult, len(entries))
@@ -76,34 +229,120 @@ func LoadHistory() ([]ScanResult, error) {
 			Vendor:    e.Vendor,
 			Method:    e.Method,
 			OpenPorts: e.OpenPorts,
+			Role:      e.Role,
 		}
 	}
 	return results, nil
 }
 
+// readHistoryFile reads and decodes the history file at p.
+func readHistoryFile(p string) ([]historyEntry, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := decodeHistory(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(p), err)
+	}
+	return entries, nil
+}
+
+// decodeHistory parses history data of any known version and returns the
+// entries in the current layout. Unversioned files (a bare JSON array, as
+// written before versioning was introduced) are treated as version 0 and
+// migrated to v1.
+func decodeHistory(data []byte) ([]historyEntry, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []historyEntry
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, err
+		}
+		return migrateHistoryV0(entries), nil
+	}
+
+	var file historyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	switch file.Version {
+	case HistoryVersion:
+		return file.Hosts, nil
+	default:
+		return nil, fmt.Errorf("unsupported history version %d", file.Version)
+	}
+}
+
+// migrateHistoryV0 upgrades unversioned history entries to v1.
+// The entry layout is unchanged between the two, so this is the identity.
+func migrateHistoryV0(entries []historyEntry) []historyEntry {
+	return entries
+}
+
 // ComputeDiff compares current results with previous results and sets
 // the Status field: "NEW" for hosts not in previous, "GONE" for hosts
-// only in previous (appended to results with status "GONE").
-// Hosts present in both get an empty Status (continuing).
+// only in previous (appended to results with status "GONE"), and "CHANGED"
+// for hosts whose open TCP ports differ, with the difference in
+// PortChanges. Other hosts present in both get an empty Status (continuing).
 func ComputeDiff(current, previous []ScanResult) []ScanResult {
-	prevSet := make(map[string]bool)
+	return ComputeDiffIgnoring(current, previous, nil)
+}
+
+// DiffIgnoreRoles maps the names accepted by ParseDiffIgnore to roles.
+var DiffIgnoreRoles = map[string]string{
+	"self":    "this host",
+	"gateway": "gateway",
+}
+
+// ParseDiffIgnore parses a comma-separated list of DiffIgnoreRoles names,
+// such as "self,gateway", into the roles to pass to ComputeDiffIgnoring.
+func ParseDiffIgnore(s string) ([]string, error) {
+	var roles []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		role, ok := DiffIgnoreRoles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q (use self or gateway)", name)
+		}
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// ComputeDiffIgnoring is ComputeDiff, except that hosts whose Role is in
+// roles never become NEW or GONE. They keep their place in current, so
+// they are still listed, just without a status.
+func ComputeDiffIgnoring(current, previous []ScanResult, roles []string) []ScanResult {
+	prevPorts := make(map[string][]int)
 	for _, r := range previous {
-		prevSet[r.IP.String()] = true
+		prevPorts[r.IP.String()] = r.OpenPorts
 	}
 
 	curSet := make(map[string]bool)
 	for i := range current {
 		ip := current[i].IP.String()
 		curSet[ip] = true
-		if !prevSet[ip] {
+		ports, known := prevPorts[ip]
+		if !known && !slices.Contains(roles, current[i].Role) {
 			current[i].Status = "NEW"
 		}
+		if known {
+			if d := diffPorts(ports, current[i].OpenPorts); d != nil {
+				current[i].Status = "CHANGED"
+				current[i].PortChanges = d
+			}
+		}
 	}
 
 	// Append GONE entries for hosts in previous but not in current
 	for _, r := range previous {
 		ip := r.IP.String()
-		if !curSet[ip] {
+		if !curSet[ip] && !slices.Contains(roles, r.Role) {
 			gone := r
 			gone.Status = "GONE"
 			current = append(current, gone)
@@ -112,3 +351,31 @@ func ComputeDiff(current, previous []ScanResult) []ScanResult {
 
 	return current
 }
+
+// PortDiff is the change in a host's open TCP ports between two scans.
+type PortDiff struct {
+	Opened []int `json:"opened,omitempty"` // open now, not before
+	Closed []int `json:"closed,omitempty"` // open before, not now
+}
+
+// diffPorts compares two open-port lists, or returns nil if they hold the
+// same ports.
+func diffPorts(previous, current []int) *PortDiff {
+	var d PortDiff
+	for _, p := range current {
+		if !slices.Contains(previous, p) {
+			d.Opened = append(d.Opened, p)
+		}
+	}
+	for _, p := range previous {
+		if !slices.Contains(current, p) {
+			d.Closed = append(d.Closed, p)
+		}
+	}
+	if d.Opened == nil && d.Closed == nil {
+		return nil
+	}
+	slices.Sort(d.Opened)
+	slices.Sort(d.Closed)
+	return &d
+}
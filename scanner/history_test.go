@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestLoadHistoryFallsBackToBackup(t *testing.T) {
+	withDataDir(t)
+	first := []ScanResult{{IP: net.ParseIP("192.168.1.10").To4(), MAC: "AA:BB:CC:00:00:01", Method: "TCP", OpenPorts: []int{22}}}
+	second := []ScanResult{{IP: net.ParseIP("192.168.1.20").To4(), MAC: "AA:BB:CC:00:00:02", Method: "ICMP"}}
+	if err := SaveHistory(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveHistory(second); err != nil { // moves first to the backup
+		t.Fatal(err)
+	}
+
+	// Truncate the primary file mid-document, as a crash during a write would
+	data, err := os.ReadFile(historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(historyPath(), data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := LoadHistory()
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	if len(results) != 1 || !results[0].IP.Equal(first[0].IP) || results[0].MAC != first[0].MAC {
+		t.Errorf("LoadHistory = %+v, want the backup's host %s", results, first[0].IP)
+	}
+}
+
+func TestLoadHistoryBothCorrupt(t *testing.T) {
+	withDataDir(t)
+	for _, p := range []string{historyPath(), backupPath(historyPath())} {
+		if err := os.WriteFile(p, []byte(`{"version": 1, "hosts": [`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadHistory(); err == nil {
+		t.Error("LoadHistory succeeded with a corrupt primary and backup")
+	}
+}
@@ -0,0 +1,289 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net"
+	"strings"
+)
+
+// hostSource is a set of hosts that can be enumerated lazily, so large
+// ranges never have to be held in memory as a slice.
+type hostSource interface {
+	hosts() iter.Seq[net.IP]
+	contains(ip net.IP) bool
+	count() int
+}
+
+// networkSource yields the usable hosts of a network, minus excluded IPs.
+type networkSource struct {
+	network *net.IPNet
+	exclude map[string]bool
+}
+
+func (s networkSource) hosts() iter.Seq[net.IP] {
+	return func(yield func(net.IP) bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel() // releases the producer when the scan stops early
+		for ip := range HostsInNetworkIter(ctx, s.network) {
+			if s.exclude[ip.String()] {
+				continue
+			}
+			if !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+func (s networkSource) contains(ip net.IP) bool {
+	if s.exclude[ip.String()] {
+		return false
+	}
+	first, last, ok := hostRange(s.network)
+	if !ok {
+		return false
+	}
+	v := ipToUint32(ip)
+	return ip.To4() != nil && v >= first && v <= last
+}
+
+func (s networkSource) count() int {
+	first, last, ok := hostRange(s.network)
+	if !ok {
+		return 0
+	}
+	n := int(last - first + 1)
+	for ex := range s.exclude {
+		if v := ipToUint32(net.ParseIP(ex)); v >= first && v <= last {
+			n--
+		}
+	}
+	return n
+}
+
+// listSource yields an explicit host list.
+type listSource struct {
+	list []net.IP
+	set  map[string]bool
+}
+
+// newListSource builds a source from hosts, dropping repeated addresses
+// (compared in canonical form, so 4- and 16-byte forms of an IPv4 address
+// are the same host) and keeping the first occurrence's position.
+func newListSource(hosts []net.IP) listSource {
+	set := make(map[string]bool, len(hosts))
+	list := make([]net.IP, 0, len(hosts))
+	for _, ip := range hosts {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		key := ip.String()
+		if set[key] {
+			continue
+		}
+		set[key] = true
+		list = append(list, ip)
+	}
+	return listSource{list: list, set: set}
+}
+
+func (s listSource) hosts() iter.Seq[net.IP] {
+	return func(yield func(net.IP) bool) {
+		for _, ip := range s.list {
+			if !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+func (s listSource) contains(ip net.IP) bool { return s.set[ip.String()] }
+func (s listSource) count() int              { return len(s.set) }
+
+// maxRangeHosts caps the size of a start-end address range, like a /8.
+const maxRangeHosts = 1 << 24
+
+// rangeSource yields every address from first to last inclusive, with no
+// regard for subnet boundaries: 192.168.1.200-192.168.2.50 includes
+// 192.168.1.255 and 192.168.2.0.
+type rangeSource struct {
+	first, last uint32
+}
+
+// parseRange parses "start-end" with two IPv4 addresses. ok is false if spec
+// is not of that form (it may be a hostname containing a dash); err is set
+// if it is, but the range is empty or too large.
+func parseRange(spec string) (s rangeSource, ok bool, err error) {
+	from, to, found := strings.Cut(spec, "-")
+	if !found {
+		return rangeSource{}, false, nil
+	}
+	start := net.ParseIP(strings.TrimSpace(from)).To4()
+	end := net.ParseIP(strings.TrimSpace(to)).To4()
+	if start == nil || end == nil {
+		return rangeSource{}, false, nil
+	}
+	s = rangeSource{first: ipToUint32(start), last: ipToUint32(end)}
+	if s.first > s.last {
+		return rangeSource{}, true, fmt.Errorf("invalid range %q: start is after end", spec)
+	}
+	if s.count() > maxRangeHosts {
+		return rangeSource{}, true, fmt.Errorf("invalid range %q: %d addresses exceeds the limit of %d", spec, s.count(), maxRangeHosts)
+	}
+	return s, true, nil
+}
+
+func (s rangeSource) hosts() iter.Seq[net.IP] {
+	return func(yield func(net.IP) bool) {
+		for v := uint64(s.first); v <= uint64(s.last); v++ {
+			if !yield(uint32ToIP(uint32(v))) {
+				return
+			}
+		}
+	}
+}
+
+func (s rangeSource) contains(ip net.IP) bool {
+	v := ipToUint32(ip)
+	return ip.To4() != nil && v >= s.first && v <= s.last
+}
+
+func (s rangeSource) count() int { return int(s.last-s.first) + 1 }
+
+// interleave yields hosts from all sources in round-robin order so every
+// subnet progresses concurrently. An IP is only yielded by the first source
+// that contains it, which deduplicates overlapping sources without
+// remembering every IP seen.
+func interleave(sources []hostSource) iter.Seq[net.IP] {
+	return func(yield func(net.IP) bool) {
+		nexts := make([]func() (net.IP, bool), len(sources))
+		for i, s := range sources {
+			next, stop := iter.Pull(s.hosts())
+			defer stop()
+			nexts[i] = next
+		}
+
+		for active := len(nexts); active > 0; {
+			active = 0
+			for i, next := range nexts {
+				if next == nil {
+					continue
+				}
+				ip, ok := next()
+				if !ok {
+					nexts[i] = nil
+					continue
+				}
+				active++
+				if anyContains(sources[:i], ip) {
+					continue
+				}
+				if !yield(ip) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// countHosts returns the number of distinct hosts across sources.
+func countHosts(sources []hostSource) int {
+	if len(sources) == 1 {
+		return sources[0].count()
+	}
+	n := 0
+	for i, s := range sources {
+		for ip := range s.hosts() {
+			if !anyContains(sources[:i], ip) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// anyContains reports whether any of sources contains ip.
+func anyContains(sources []hostSource, ip net.IP) bool {
+	for _, s := range sources {
+		if s.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostRange returns the first and last usable host addresses of an IPv4
+// network as integers. A /31 has two hosts and a /32 one, as neither has
+// network or broadcast addresses. ok is false for non-IPv4 and /0 networks.
+func hostRange(network *net.IPNet) (first, last uint32, ok bool) {
+	ones, bits := network.Mask.Size()
+	if network.IP.To4() == nil || bits-ones > 32 || ones == 0 {
+		return 0, 0, false
+	}
+	base := ipToUint32(network.IP.Mask(network.Mask))
+	switch bits - ones {
+	case 0: // /32: the address itself
+		return base, base, true
+	case 1: // /31 point-to-point link (RFC 3021): both addresses are hosts
+		return base, base + 1, true
+	}
+	size := uint32(1) << uint(bits-ones)
+	return base + 1, base + size - 2, true
+}
+
+// emptyNetworkHint explains why the networks yielded no hosts to scan.
+func emptyNetworkHint(networks []*net.IPNet) string {
+	for _, n := range networks {
+		if ones, _ := n.Mask.Size(); ones == 0 {
+			return "a /0 mask covers the whole IPv4 space and is not enumerated; use -target with a smaller range"
+		}
+	}
+	if len(networks) > 0 {
+		if ones, _ := networks[0].Mask.Size(); ones == 32 {
+			return "a /32 network holds only this host's own address; use -target to scan other hosts (e.g. -target 10.0.0.0/24)"
+		}
+	}
+	return "every address in it belongs to this host; use -target to scan other hosts"
+}
+
+// networkHosts yields the usable hosts of network without materializing them.
+func networkHosts(network *net.IPNet) iter.Seq[net.IP] {
+	return func(yield func(net.IP) bool) {
+		first, last, ok := hostRange(network)
+		if !ok {
+			return
+		}
+		for v := first; ; v++ {
+			if !yield(uint32ToIP(v)) || v == last {
+				return
+			}
+		}
+	}
+}
+
+// HostsInNetworkIter streams the usable host IPs of network, like
+// HostsInNetwork, without allocating the whole range up front, so memory use
+// stays flat however large the network is. Scans read their networks through
+// it. The channel is closed after the last host or once ctx is done; a
+// caller that stops reading early must cancel ctx to stop the goroutine
+// that feeds it.
+func HostsInNetworkIter(ctx context.Context, network *net.IPNet) <-chan net.IP {
+	ch := make(chan net.IP, 64)
+	go func() {
+		defer close(ch)
+		for ip := range networkHosts(network) {
+			select {
+			case ch <- ip:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func uint32ToIP(v uint32) net.IP {
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).To4()
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHostsInNetworkIter(t *testing.T) {
+	_, network, _ := net.ParseCIDR("192.168.1.0/29")
+	var got []net.IP
+	for ip := range HostsInNetworkIter(context.Background(), network) {
+		got = append(got, ip)
+	}
+	if want := HostsInNetwork(network); !slices.EqualFunc(got, want, net.IP.Equal) {
+		t.Errorf("HostsInNetworkIter = %v, want %v", got, want)
+	}
+}
+
+// TestHostsInNetworkIterCancel checks that a reader can stop early: once ctx
+// is cancelled the channel is closed instead of the producer blocking.
+func TestHostsInNetworkIterCancel(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := HostsInNetworkIter(ctx, network)
+	<-ch
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel not closed after ctx was cancelled")
+		}
+	}
+}
+
+// TestNetworkSourceStopsEarly stops reading a /8 source after a few hosts,
+// as a cancelled scan does, which must not enumerate the rest.
+func TestNetworkSourceStopsEarly(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	s := networkSource{network: network, exclude: map[string]bool{"10.0.0.2": true}}
+	var got []string
+	for ip := range s.hosts() {
+		if got = append(got, ip.String()); len(got) == 3 {
+			break
+		}
+	}
+	if want := []string{"10.0.0.1", "10.0.0.3", "10.0.0.4"}; !slices.Equal(got, want) {
+		t.Errorf("hosts = %v, want %v", got, want)
+	}
+}
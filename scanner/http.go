@@ -0,0 +1,83 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxHTTPRedirects bounds how many redirects are followed when probing HTTP.
+const maxHTTPRedirects = 5
+
+// Path fragments that suggest a redirect lands on a captive portal or a
+// router/login page rather than actual content.
+var captivePortalHints = []string{
+	"captive", "portal", "login", "logon", "hotspot", "splash", "auth", "guest",
+}
+
+// HTTPInfo describes how a host's web server on port 80 responded.
+type HTTPInfo struct {
+	Status        int    // HTTP status code of the final response
+	RedirectHost  string // host of the final URL when redirected ("" otherwise)
+	CaptivePortal bool   // redirect looks like a captive portal or login page
+}
+
+// newHTTPClient returns a client bounded by timeout that does not keep
+// connections alive and follows at most maxHTTPRedirects redirects.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       (&net.Dialer{Timeout: timeout}).DialContext,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxHTTPRedirects {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// ProbeHTTP requests / on port 80 of ip and reports where it ends up.
+func ProbeHTTP(ip string, timeout time.Duration) (HTTPInfo, error) {
+	return probeHTTP(context.Background(), ip, timeout)
+}
+
+// probeHTTP is ProbeHTTP, giving up once ctx is done.
+func probeHTTP(ctx context.Context, ip string, timeout time.Duration) (HTTPInfo, error) {
+	var info HTTPInfo
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+ip+"/", nil)
+	if err != nil {
+		return info, err
+	}
+	resp, err := newHTTPClient(timeout).Do(req)
+	if err != nil {
+		return info, err
+	}
+	resp.Body.Close()
+
+	info.Status = resp.StatusCode
+	final := resp.Request.URL
+	if final.Hostname() != ip || final.Path != "/" {
+		info.RedirectHost = final.Hostname()
+		// Redirecting off-host is portal-like by itself; a same-host
+		// redirect only counts if it lands on a login-style path.
+		info.CaptivePortal = final.Hostname() != ip || looksLikePortal(final.Path)
+	}
+	return info, nil
+}
+
+func looksLikePortal(path string) bool {
+	path = strings.ToLower(path)
+	for _, hint := range captivePortalHints {
+		if strings.Contains(path, hint) {
+			return true
+		}
+	}
+	return false
+}
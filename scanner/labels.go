@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// EthersPath is the system file mapping MAC addresses to host names.
+const EthersPath = "/etc/ethers"
+
+// LoadLabels reads a file of "MAC name" lines in /etc/ethers format and
+// returns a map from normalized MAC to name. The name is the rest of the
+// line, so it may contain spaces ("Living Room TV"). Blank lines and lines
+// starting with # are skipped, as are lines without a valid MAC.
+func LoadLabels(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	labels := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		mac, name := line[:i], strings.TrimSpace(line[i+1:])
+		if name == "" {
+			continue
+		}
+		if key, valid := labelKey(mac); valid {
+			labels[key] = name
+		}
+	}
+	return labels, sc.Err()
+}
+
+// labelKey normalizes a MAC in colon or dash notation for label lookups.
+func labelKey(mac string) (string, bool) {
+	mac = normalizeMAC(strings.ReplaceAll(mac, "-", ":"))
+	if len(strings.Split(mac, ":")) != 6 || len(mac) != 17 {
+		return "", false
+	}
+	return mac, true
+}
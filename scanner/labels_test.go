@@ -0,0 +1,38 @@
+package scanner
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ethers")
+	data := "# comment\n" +
+		"aa:bb:cc:dd:ee:ff\tLiving Room TV\n" +
+		"11-22-33-44-55-66 NAS\n" +
+		"00:11:22:33:44:55\t Printer  \n" +
+		"22:33:44:55:66:77\n" +
+		"not-a-mac Phone\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadLabels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(mac string) string {
+		k, _ := labelKey(mac)
+		return k
+	}
+	want := map[string]string{
+		key("aa:bb:cc:dd:ee:ff"): "Living Room TV",
+		key("11:22:33:44:55:66"): "NAS",
+		key("00:11:22:33:44:55"): "Printer",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("LoadLabels = %v, want %v", got, want)
+	}
+}
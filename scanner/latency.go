@@ -0,0 +1,26 @@
+package scanner
+
+import "time"
+
+// Latency buckets for ScanResult.RTT. The thresholds are rough: a busy
+// wired device can answer slowly, and a Wi-Fi client that is idle may be
+// slower still while its radio wakes up.
+const (
+	LatencyWired    = "wired"    // under 2ms: on the wired LAN
+	LatencyWireless = "wireless" // 2-20ms: Wi-Fi, or behind a mesh extender
+	LatencyRemote   = "remote"   // over 20ms: routed elsewhere, or slow to answer
+)
+
+// LatencyBucket classifies a round-trip time, or returns "" if it is
+// unknown (zero).
+func LatencyBucket(rtt time.Duration) string {
+	switch {
+	case rtt <= 0:
+		return ""
+	case rtt < 2*time.Millisecond:
+		return LatencyWired
+	case rtt <= 20*time.Millisecond:
+		return LatencyWireless
+	}
+	return LatencyRemote
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// linkSpeed reads the negotiated link speed in Mbit/s from sysfs. Wireless
+// and virtual interfaces usually do not report one.
+func linkSpeed(name string) int {
+	data, err := os.ReadFile(filepath.Join("/sys/class/net", name, "speed"))
+	if err != nil {
+		return 0
+	}
+	speed, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || speed < 0 {
+		return 0
+	}
+	return speed
+}
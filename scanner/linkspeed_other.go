@@ -0,0 +1,9 @@
+//go:build !linux
+
+package scanner
+
+// linkSpeed is not implemented on this platform; the speed is reported as
+// unknown.
+func linkSpeed(name string) int {
+	return 0
+}
@@ -0,0 +1,264 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DNS record types used in mDNS queries.
+const (
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+)
+
+// dnsRecord is a resource record decoded from an mDNS response.
+type dnsRecord struct {
+	Name   string
+	Type   int
+	Target string   // PTR and SRV target name
+	Port   int      // SRV port
+	TXT    []string // TXT strings
+	Addr   net.IP   // A record address
+}
+
+// buildDNSQuery builds a DNS query packet for name with the given record type.
+func buildDNSQuery(name string, qtype int) []byte {
+	var buf []byte
+	buf = append(buf, 0x00, 0x00) // Transaction ID
+	buf = append(buf, 0x00, 0x00) // Flags: standard query
+	buf = append(buf, 0x00, 0x01) // Questions: 1
+	buf = append(buf, 0x00, 0x00) // Answers: 0
+	buf = append(buf, 0x00, 0x00) // Authority: 0
+	buf = append(buf, 0x00, 0x00) // Additional: 0
+
+	for _, label := range strings.Split(name, ".") {
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, []byte(label)...)
+	}
+	buf = append(buf, 0x00)                             // End of name
+	buf = append(buf, byte(qtype>>8), byte(qtype&0xFF)) // Type
+	buf = append(buf, 0x00, 0x01)                       // Class: IN
+	return buf
+}
+
+// parseDNSRecords decodes the answer, authority and additional sections of a
+// DNS response. Malformed trailing data ends parsing without an error.
+func parseDNSRecords(data []byte) []dnsRecord {
+	if len(data) < 12 {
+		return nil
+	}
+	qdcount := int(data[4])<<8 | int(data[5])
+	rrcount := (int(data[6])<<8 | int(data[7])) +
+		(int(data[8])<<8 | int(data[9])) +
+		(int(data[10])<<8 | int(data[11]))
+
+	offset := 12
+	for i := 0; i < qdcount; i++ {
+		offset = skipDNSName(data, offset)
+		if offset < 0 || offset+4 > len(data) {
+			return nil
+		}
+		offset += 4 // type + class
+	}
+
+	var records []dnsRecord
+	for i := 0; i < rrcount; i++ {
+		name := readDNSName(data, offset)
+		offset = skipDNSName(data, offset)
+		if offset < 0 || offset+10 > len(data) {
+			break
+		}
+		rtype := int(data[offset])<<8 | int(data[offset+1])
+		rdlen := int(data[offset+8])<<8 | int(data[offset+9])
+		offset += 10 // type(2) + class(2) + TTL(4) + rdlength(2)
+		if offset+rdlen > len(data) {
+			break
+		}
+		rdata := data[offset : offset+rdlen]
+
+		rec := dnsRecord{Name: strings.TrimSuffix(name, "."), Type: rtype}
+		switch rtype {
+		case dnsTypeA:
+			if rdlen == 4 {
+				rec.Addr = net.IP(append([]byte(nil), rdata...))
+			}
+		case dnsTypePTR:
+			rec.Target = readDNSName(data, offset)
+		case dnsTypeSRV:
+			if rdlen >= 7 {
+				rec.Port = int(rdata[4])<<8 | int(rdata[5])
+				rec.Target = readDNSName(data, offset+6)
+			}
+		case dnsTypeTXT:
+			for j := 0; j < len(rdata); {
+				l := int(rdata[j])
+				j++
+				if j+l > len(rdata) {
+					break
+				}
+				if l > 0 {
+					rec.TXT = append(rec.TXT, string(rdata[j:j+l]))
+				}
+				j += l
+			}
+		}
+		records = append(records, rec)
+		offset += rdlen
+	}
+	return records
+}
+
+// mdnsQuery sends a unicast mDNS query to the host on port 5353 and returns
+// the records of the first response, or nothing once ctx is done.
+func mdnsQuery(ctx context.Context, ip, name string, qtype int, timeout time.Duration) []dnsRecord {
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "udp", net.JoinHostPort(ip, "5353"))
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(buildDNSQuery(name, qtype)); err != nil {
+		return nil
+	}
+
+	buf := make([]byte, 9000)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil
+	}
+	return parseDNSRecords(buf[:n])
+}
+
+// txtValue returns the value of key in TXT strings of the form key=value.
+func txtValue(txt []string, key string) string {
+	for _, kv := range txt {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// servicesMetaQuery is the DNS-SD name that lists all advertised service types.
+const servicesMetaQuery = "_services._dns-sd._udp.local"
+
+// mdnsQueryTimeout caps a single query while browsing a host's services.
+const mdnsQueryTimeout = 500 * time.Millisecond
+
+// ServiceInstance is a Bonjour service instance a host advertises, with the
+// port, host and metadata from its SRV and TXT records.
+type ServiceInstance struct {
+	Type string   `json:"type"`           // service type, e.g. "_ipp._tcp"
+	Name string   `json:"name"`           // instance name, e.g. "Office Printer"
+	Host string   `json:"host,omitempty"` // SRV target, e.g. "printer.local"
+	Port int      `json:"port,omitempty"` // SRV port, 0 if unknown
+	TXT  []string `json:"txt,omitempty"`  // TXT strings, usually key=value
+}
+
+// serviceInstances returns the instances of service (e.g. "_ipp._tcp.local")
+// named by PTR records, filled in from SRV and TXT records among the same
+// records, which responders usually send in the additional section.
+func serviceInstances(records []dnsRecord, service string) []ServiceInstance {
+	var instances []ServiceInstance
+	for _, rec := range records {
+		if rec.Type != dnsTypePTR || !strings.EqualFold(rec.Name, service) || rec.Target == "" {
+			continue
+		}
+		inst := ServiceInstance{
+			Type: strings.TrimSuffix(service, ".local"),
+			Name: strings.TrimSuffix(rec.Target, "."+service),
+		}
+		fillInstance(&inst, records, rec.Target)
+		instances = append(instances, inst)
+	}
+	return instances
+}
+
+// fillInstance copies the SRV and TXT data for the instance named name from
+// records into inst, keeping what is already set.
+func fillInstance(inst *ServiceInstance, records []dnsRecord, name string) {
+	for _, rec := range records {
+		if !strings.EqualFold(rec.Name, name) {
+			continue
+		}
+		switch {
+		case rec.Type == dnsTypeSRV && inst.Port == 0:
+			inst.Host, inst.Port = rec.Target, rec.Port
+		case rec.Type == dnsTypeTXT && inst.TXT == nil:
+			inst.TXT = rec.TXT
+		}
+	}
+}
+
+// BrowseServices enumerates the Bonjour service types a host advertises over
+// unicast mDNS (e.g. "_ipp._tcp", "_googlecast._tcp"), along with their
+// instances. Each type is confirmed by looking up its instances, whose SRV
+// and TXT records are taken from the additional section of the answer or
+// else queried one by one. The whole enumeration stops after budget, so the
+// last instances may lack their port or metadata.
+func BrowseServices(ip string, budget time.Duration) ([]string, []ServiceInstance) {
+	return browseServices(context.Background(), ip, budget)
+}
+
+// browseServices is BrowseServices, stopping early once ctx is done.
+func browseServices(ctx context.Context, ip string, budget time.Duration) ([]string, []ServiceInstance) {
+	deadline := time.Now().Add(budget)
+	queryTimeout := func() time.Duration {
+		return min(time.Until(deadline), mdnsQueryTimeout)
+	}
+
+	var types []string
+	seen := make(map[string]bool)
+	for _, rec := range mdnsQuery(ctx, ip, servicesMetaQuery, dnsTypePTR, queryTimeout()) {
+		if rec.Type != dnsTypePTR || !strings.EqualFold(rec.Name, servicesMetaQuery) {
+			continue
+		}
+		t := strings.TrimSuffix(rec.Target, ".local")
+		if t != "" && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+
+	var (
+		services  []string
+		instances []ServiceInstance
+	)
+	for _, t := range types {
+		timeout := queryTimeout()
+		if timeout <= 0 {
+			break
+		}
+		found := serviceInstances(mdnsQuery(ctx, ip, t+".local", dnsTypePTR, timeout), t+".local")
+		if len(found) == 0 {
+			continue
+		}
+		services = append(services, t)
+		for i := range found {
+			inst := &found[i]
+			name := inst.Name + "." + t + ".local"
+			if timeout := queryTimeout(); inst.Port == 0 && timeout > 0 {
+				fillInstance(inst, mdnsQuery(ctx, ip, name, dnsTypeSRV, timeout), name)
+			}
+			if timeout := queryTimeout(); inst.TXT == nil && timeout > 0 {
+				fillInstance(inst, mdnsQuery(ctx, ip, name, dnsTypeTXT, timeout), name)
+			}
+		}
+		instances = append(instances, found...)
+	}
+	sort.Strings(services)
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Type != instances[j].Type {
+			return instances[i].Type < instances[j].Type
+		}
+		return instances[i].Name < instances[j].Name
+	})
+	return services, instances
+}
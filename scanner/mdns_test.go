@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServiceInstances(t *testing.T) {
+	records := []dnsRecord{
+		{Name: "_ipp._tcp.local", Type: dnsTypePTR, Target: "Office Printer._ipp._tcp.local"},
+		{Name: "_IPP._tcp.local", Type: dnsTypePTR, Target: "Lobby._ipp._tcp.local"},
+		{Name: "_http._tcp.local", Type: dnsTypePTR, Target: "Admin._http._tcp.local"},
+		// Additional section: SRV and TXT for the first instance only
+		{Name: "office printer._ipp._tcp.local", Type: dnsTypeSRV, Target: "printer.local", Port: 631},
+		{Name: "Office Printer._ipp._tcp.local", Type: dnsTypeTXT, TXT: []string{"ty=LaserJet", "rp=ipp/print"}},
+		{Name: "printer.local", Type: dnsTypeA},
+	}
+
+	got := serviceInstances(records, "_ipp._tcp.local")
+	want := []ServiceInstance{
+		{Type: "_ipp._tcp", Name: "Office Printer", Host: "printer.local", Port: 631, TXT: []string{"ty=LaserJet", "rp=ipp/print"}},
+		{Type: "_ipp._tcp", Name: "Lobby"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("serviceInstances = %+v, want %+v", got, want)
+	}
+
+	// A later SRV or TXT answer fills in what the first response lacked
+	lobby := got[1]
+	fillInstance(&lobby, []dnsRecord{
+		{Name: "Lobby._ipp._tcp.local", Type: dnsTypeSRV, Target: "lobby.local", Port: 631},
+		{Name: "Lobby._ipp._tcp.local", Type: dnsTypeTXT, TXT: []string{"ty=Inkjet"}},
+	}, "Lobby._ipp._tcp.local")
+	if lobby.Host != "lobby.local" || lobby.Port != 631 || !reflect.DeepEqual(lobby.TXT, []string{"ty=Inkjet"}) {
+		t.Errorf("fillInstance = %+v", lobby)
+	}
+
+	if got := serviceInstances(records, "_ssh._tcp.local"); got != nil {
+		t.Errorf("serviceInstances(_ssh._tcp) = %+v, want none", got)
+	}
+}
@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"net"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// GetNeighbors6 reads the system IPv6 neighbor cache and returns the
+// addresses known for each MAC (upper-case, colon-separated). Multicast
+// entries and entries still being resolved are skipped.
+func GetNeighbors6() map[string][]net.IP {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("netsh", "interface", "ipv6", "show", "neighbors")
+	case "linux":
+		cmd = exec.Command("ip", "-6", "neigh", "show")
+	default:
+		cmd = exec.Command("ndp", "-an")
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	neighbors := make(map[string][]net.IP)
+	for _, line := range strings.Split(string(out), "\n") {
+		ip, mac := parseNeighbor6Line(line)
+		if ip != nil {
+			neighbors[mac] = append(neighbors[mac], ip)
+		}
+	}
+	return neighbors
+}
+
+// parseNeighbor6Line extracts the IPv6 address and MAC from a line of
+// `ip -6 neigh` (Linux), `ndp -an` (macOS) or `netsh interface ipv6 show
+// neighbors` (Windows) output. Zone suffixes such as %en0 are dropped.
+func parseNeighbor6Line(line string) (net.IP, string) {
+	var (
+		ip  net.IP
+		mac string
+	)
+	for _, field := range strings.Fields(line) {
+		addr, _, _ := strings.Cut(field, "%")
+		if parsed := net.ParseIP(addr); ip == nil && parsed != nil && parsed.To4() == nil {
+			ip = parsed
+			continue
+		}
+		if key, ok := labelKey(field); ok && mac == "" {
+			mac = key
+		}
+	}
+	if ip == nil || mac == "" || ip.IsMulticast() || strings.HasPrefix(mac, "33:33:") {
+		return nil, ""
+	}
+	return ip, mac
+}
+
+// joinNeighbors6 records the IPv6 addresses that share each result's MAC,
+// so a dual-stack device is one host with several addresses: Addresses
+// lists the IPv4 address first, then global and then link-local IPv6
+// addresses. Results without a MAC or without IPv6 neighbors are left as
+// they are.
+func joinNeighbors6(results []ScanResult, neighbors map[string][]net.IP) {
+	if len(neighbors) == 0 {
+		return
+	}
+	for i := range results {
+		mac, ok := labelKey(results[i].MAC)
+		if !ok || len(neighbors[mac]) == 0 {
+			continue
+		}
+		addrs := []net.IP{results[i].IP}
+		for _, linkLocal := range []bool{false, true} {
+			for _, ip := range neighbors[mac] {
+				if ip.IsLinkLocalUnicast() == linkLocal && !containsIP(addrs, ip) {
+					addrs = append(addrs, ip)
+				}
+			}
+		}
+		results[i].Addresses = addrs
+	}
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, x := range ips {
+		if x.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	netDownAfter   = 16               // hosts failing in a row with a network-down error
+	netWaitTimeout = 15 * time.Second // how long to wait for the network to return
+	netMaxResumes  = 3                // resumes before giving up on a flapping network
+)
+
+// ErrNetworkDown is returned when the network became unusable during a scan,
+// e.g. because Wi-Fi dropped, and did not come back.
+var ErrNetworkDown = errors.New("network went down during the scan")
+
+// netWatch notices when every probe starts failing because the interface
+// went away, so the remaining hosts are not silently reported as absent.
+type netWatch struct {
+	mu      sync.Mutex
+	failed  []net.IP // hosts that failed since the network last worked
+	lastErr error
+	resumes int
+	down    chan struct{} // signalled once a run of failures is long enough
+}
+
+func newNetWatch() *netWatch {
+	return &netWatch{down: make(chan struct{}, 1)}
+}
+
+// observe records the outcome of probing ip. Any result other than a
+// network-down error shows the network still works and ends the run.
+func (w *netWatch) observe(ip net.IP, found bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if found || err == nil || !isNetDownErr(err) {
+		w.failed = w.failed[:0]
+		return
+	}
+	w.failed = append(w.failed, ip)
+	w.lastErr = err
+	if len(w.failed) == netDownAfter {
+		select {
+		case w.down <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wait polls until a route to the failed hosts exists again and returns
+// the hosts to probe again. It gives up after netWaitTimeout, or once the
+// network has come back netMaxResumes times only to fail again.
+func (w *netWatch) wait(ctx context.Context) ([]net.IP, error) {
+	w.mu.Lock()
+	if len(w.failed) == 0 { // a host answered since the signal
+		w.mu.Unlock()
+		return nil, nil
+	}
+	probe, lastErr := w.failed[0], w.lastErr
+	w.resumes++
+	resumes := w.resumes
+	w.mu.Unlock()
+
+	fail := fmt.Errorf("%w: %d hosts in a row failed (%v)", ErrNetworkDown, netDownAfter, lastErr)
+	if resumes > netMaxResumes {
+		return nil, fail
+	}
+
+	deadline := time.Now().Add(netWaitTimeout)
+	for !routeExists(probe) {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w; not back within %s", fail, netWaitTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(time.Second):
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	retry := append([]net.IP(nil), w.failed...)
+	w.failed = w.failed[:0]
+	return retry, nil
+}
+
+// routeExists reports whether the system has a route to ip. No packets
+// are sent.
+func routeExists(ip net.IP) bool {
+	conn, err := net.Dial("udp4", net.JoinHostPort(ip.String(), "9"))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
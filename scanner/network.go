@@ -3,18 +3,37 @@ package scanner
 import (
 	"fmt"
 	"net"
+	"os"
+	"strings"
 )
 
 // InterfaceInfo holds network interface details.
 type InterfaceInfo struct {
 	Name    string
 	IP      net.IP
-	Network *net.IPNet
+	Network *net.IPNet // primary network
+	MAC     string
+
+	// Networks lists every IPv4 network on the interface (aliases
+	// included), with the primary network first.
+	Networks []*net.IPNet
+
+	MTU     int  // maximum transmission unit in bytes
+	Speed   int  // link speed in Mbit/s, 0 if unknown
+	Virtual bool // name matches a VPN/tunnel/bridge pattern
+}
+
+// Name prefixes of tunnel, bridge, and hypervisor interfaces that are
+// usually not the LAN the user wants to scan.
+var virtualPrefixes = []string{
+	"utun", "tun", "tap", "docker", "br-", "veth", "vboxnet",
 }
 
 // DetectInterface finds an active non-loopback IPv4 interface.
 // If ifaceName is non-empty, it looks for that specific interface.
-func DetectInterface(ifaceName string) (*InterfaceInfo, error) {
+// Virtual interfaces (VPN tunnels, docker bridges, etc.) are skipped during
+// auto-detection unless includeVirtual is set.
+func DetectInterface(ifaceName string, includeVirtual bool) (*InterfaceInfo, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("list interfaces: %w", err)
@@ -30,25 +49,11 @@ func DetectInterface(ifaceName string) (*InterfaceInfo, error) {
 		if ifaceName != "" && iface.Name != ifaceName {
 			continue
 		}
-
-		addrs, err := iface.Addrs()
-		if err != nil {
+		if ifaceName == "" && !includeVirtual && isVirtualInterface(iface.Name) {
 			continue
 		}
-		for _, addr := range addrs {
-			ipNet, ok := addr.(*net.IPNet)
-			if !ok {
-				continue
-			}
-			ip4 := ipNet.IP.To4()
-			if ip4 == nil {
-				continue
-			}
-			return &InterfaceInfo{
-				Name:    iface.Name,
-				IP:      ip4,
-				Network: ipNet,
-			}, nil
+		if info := interfaceInfo(iface); info != nil {
+			return info, nil
 		}
 	}
 
@@ -58,50 +63,177 @@ func DetectInterface(ifaceName string) (*InterfaceInfo, error) {
 	return nil, fmt.Errorf("no active network interface found")
 }
 
-// HostsInNetwork returns all usable host IPs in the given network (excluding network and broadcast addresses).
-func HostsInNetwork(network *net.IPNet) []net.IP {
-	var hosts []net.IP
-	ip := network.IP.Mask(network.Mask)
+// DefaultRouteInterface describes the source address the system would use
+// for the default route, without relying on interface enumeration. Only IP
+// and Network (a /32) are set. No packets are sent.
+func DefaultRouteInterface() (*InterfaceInfo, error) {
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err != nil {
+		return nil, fmt.Errorf("find default route: %w", err)
+	}
+	defer conn.Close()
 
-	// Calculate the number of bits in the mask
-	ones, bits := network.Mask.Size()
-	if ones == 0 || ones == bits {
-		return nil
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("default route has no IPv4 source address")
 	}
+	network := &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}
+	return &InterfaceInfo{IP: ip, Network: network, Networks: []*net.IPNet{network}}, nil
+}
 
-	// Iterate all IPs in range
-	for current := cloneIP(ip); network.Contains(current); incIP(current) {
-		hosts = append(hosts, cloneIP(current))
+// ListInterfaces returns every active non-loopback interface with an IPv4
+// address, virtual ones included, in system order.
+func ListInterfaces() ([]*InterfaceInfo, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("list interfaces: %w", err)
+	}
+	var infos []*InterfaceInfo
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		if info := interfaceInfo(iface); info != nil {
+			infos = append(infos, info)
+		}
 	}
+	return infos, nil
+}
 
-	// Remove network address (first) and broadcast address (last)
-	if len(hosts) > 2 {
-		hosts = hosts[1 : len(hosts)-1]
-	} else {
-		hosts = nil
+// interfaceInfo collects the IPv4 networks and link details of iface, or
+// returns nil if it has no IPv4 address.
+func interfaceInfo(iface net.Interface) *InterfaceInfo {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	var networks []*net.IPNet
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip4 := ipNet.IP.To4()
+		if ip4 == nil {
+			continue
+		}
+		networks = append(networks, &net.IPNet{IP: ip4, Mask: ipNet.Mask[len(ipNet.Mask)-net.IPv4len:]})
+	}
+	if len(networks) == 0 {
+		return nil
+	}
+	return &InterfaceInfo{
+		Name:     iface.Name,
+		IP:       networks[0].IP,
+		Network:  networks[0],
+		Networks: networks,
+		MAC:      normalizeMAC(iface.HardwareAddr.String()),
+		MTU:      iface.MTU,
+		Speed:    linkSpeed(iface.Name),
+		Virtual:  isVirtualInterface(iface.Name),
+	}
+}
+
+// isVirtualInterface reports whether the interface name matches a known
+// virtual interface pattern.
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
 	}
+	return false
+}
 
+// HostsInNetwork returns all usable host IPs in the given network (excluding network and broadcast addresses;
+// both addresses of a /31 and the single address of a /32 are included).
+// For large networks prefer HostsInNetworkIter, which scans use and which
+// does not allocate the whole range up front.
+func HostsInNetwork(network *net.IPNet) []net.IP {
+	var hosts []net.IP
+	for ip := range networkHosts(network) {
+		hosts = append(hosts, ip)
+	}
 	return hosts
 }
 
 // CIDR returns the CIDR notation string for the network.
 func (info *InterfaceInfo) CIDR() string {
-	ones, _ := info.Network.Mask.Size()
-	networkIP := info.Network.IP.Mask(info.Network.Mask)
+	return networkCIDR(info.Network)
+}
+
+// CIDRs returns the comma-separated CIDR notation of all networks on the
+// interface, skipping duplicates.
+func (info *InterfaceInfo) CIDRs() string {
+	seen := make(map[string]bool)
+	var cidrs []string
+	for _, n := range info.Networks {
+		c := networkCIDR(n)
+		if !seen[c] {
+			seen[c] = true
+			cidrs = append(cidrs, c)
+		}
+	}
+	return strings.Join(cidrs, ",")
+}
+
+// Hosts returns the union of host IPs across all networks on the interface,
+// deduplicated and excluding the interface's own addresses.
+func (info *InterfaceInfo) Hosts() []net.IP {
+	var hosts []net.IP
+	for ip := range interleave(info.hostSources()) {
+		hosts = append(hosts, ip)
+	}
+	return hosts
+}
+
+// hostSources returns one lazy source per network on the interface, each
+// excluding the interface's own addresses.
+func (info *InterfaceInfo) hostSources() []hostSource {
+	own := make(map[string]bool)
+	for _, n := range info.Networks {
+		own[n.IP.String()] = true
+	}
+	var sources []hostSource
+	for _, n := range info.Networks {
+		sources = append(sources, networkSource{network: n, exclude: own})
+	}
+	return sources
+}
+
+func networkCIDR(network *net.IPNet) string {
+	ones, _ := network.Mask.Size()
+	networkIP := network.IP.Mask(network.Mask)
 	return fmt.Sprintf("%s/%d", networkIP.To4(), ones)
 }
 
+// SelfResult returns a scan result describing the scanning host itself,
+// which never shows up in probes or the ARP table.
+func (info *InterfaceInfo) SelfResult() ScanResult {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	mac, vendor := info.MAC, "-"
+	if mac == "" {
+		mac = "-"
+	} else {
+		vendor = LookupVendor(mac)
+	}
+	return ScanResult{
+		IP:         cloneIP(info.IP),
+		Hostname:   hostname,
+		MAC:        mac,
+		Vendor:     vendor,
+		Method:     "SELF",
+		Role:       "this host",
+		RandomMAC:  IsRandomizedMAC(mac),
+		Confidence: 100,
+	}
+}
+
 func cloneIP(ip net.IP) net.IP {
 	dup := make(net.IP, len(ip))
 	copy(dup, ip)
 	return dup
 }
-
-func incIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
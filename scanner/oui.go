@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// LoadOUIFile reads an IEEE MAC address registry, either the CSV export
+// (oui.csv) or the classic text listing (oui.txt), and returns a map from
+// normalized MAC prefix to vendor for ScanOptions.Vendors. The format is
+// told by the content, not the file name. Assignments that do not end on
+// an octet boundary (the 28- and 36-bit MA-M and MA-S blocks) are skipped,
+// since vendor lookups match whole octets.
+func LoadOUIFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var vendors map[string]string
+	if isOUICSV(data) {
+		vendors, err = parseOUICSV(bytes.NewReader(data))
+	} else {
+		vendors, err = parseOUIText(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(vendors) == 0 {
+		return nil, fmt.Errorf("%s: no OUI assignments found", path)
+	}
+	return vendors, nil
+}
+
+// isOUICSV reports whether data starts with the header row of the IEEE
+// CSV export, "Registry,Assignment,Organization Name,...".
+func isOUICSV(data []byte) bool {
+	line, _, _ := bytes.Cut(bytes.TrimLeft(data, "\ufeff \t\r\n"), []byte("\n"))
+	return bytes.HasPrefix(bytes.ToLower(line), []byte("registry,assignment,"))
+}
+
+// parseOUICSV parses the IEEE CSV export, taking the prefix and vendor
+// from the Assignment and Organization Name columns.
+func parseOUICSV(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	assignment, organization := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "assignment":
+			assignment = i
+		case "organization name":
+			organization = i
+		}
+	}
+	if assignment < 0 || organization < 0 {
+		return nil, fmt.Errorf("CSV header lacks Assignment or Organization Name column")
+	}
+
+	vendors := make(map[string]string)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return vendors, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) <= max(assignment, organization) {
+			continue
+		}
+		addOUI(vendors, rec[assignment], rec[organization])
+	}
+}
+
+// parseOUIText parses the classic oui.txt listing. Each assignment starts
+// with a line such as
+//
+//	00-1B-63   (hex)		Apple, Inc.
+//
+// followed by a "(base 16)" line and the address; only the "(hex)" lines
+// are used.
+func parseOUIText(r io.Reader) (map[string]string, error) {
+	vendors := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		prefix, vendor, ok := strings.Cut(sc.Text(), "(hex)")
+		if ok {
+			addOUI(vendors, prefix, vendor)
+		}
+	}
+	return vendors, sc.Err()
+}
+
+// addOUI adds a registry assignment to vendors if it is a whole number of
+// octets and names an organization.
+func addOUI(vendors map[string]string, prefix, vendor string) {
+	vendor = strings.TrimSpace(vendor)
+	if key, ok := NormalizeMACPrefix(prefix); ok && vendor != "" {
+		vendors[key] = vendor
+	}
+}
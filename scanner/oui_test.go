@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOUIFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want map[string]string
+	}{
+		{"oui.csv", map[string]string{
+			"00:1B:63": "Apple, Inc.",
+			"DC:A6:32": "Raspberry Pi Trading Ltd",
+		}},
+		{"oui.txt", map[string]string{
+			"00:1B:63": "Apple, Inc.",
+			"DC:A6:32": "Raspberry Pi Trading Ltd",
+			"08:00:27": "PCS Systemtechnik GmbH",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := LoadOUIFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Malformed lines, bad hex, empty organizations and blocks that
+			// do not end on an octet boundary are all skipped
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("LoadOUIFile(%s) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadOUIFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", filepath.Join(dir, "missing.txt")},
+		{"no assignments", write("empty.txt", "nothing to see here\n")},
+		{"CSV without columns", write("bad.csv", "Registry,Assignment,Address\nMA-L,001B63,Cupertino\n")},
+	}
+	for _, tt := range tests {
+		if _, err := LoadOUIFile(tt.path); err == nil {
+			t.Errorf("%s: LoadOUIFile succeeded", tt.name)
+		}
+	}
+}
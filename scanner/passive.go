@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// passive is Run for ScanOptions.Passive: it reports the plan's hosts that
+// are in the ARP table or announce themselves over mDNS or SSDP within
+// Listen, without sending a packet. Coverage depends on prior traffic, so
+// quiet devices are missed. Results only get the enrichment that needs no
+// network traffic, plus what their hosts announced.
+func (p *Plan) passive(ctx context.Context) (*Report, error) {
+	var warnings []string
+	heard := make(map[string]heardHost)
+	if p.opts.Listen > 0 {
+		var err error
+		if heard, err = listenAnnouncements(ctx, p.listenInterface(), p.opts.Listen); err != nil {
+			warnings = append(warnings, fmt.Sprintf("listen: %v", err))
+		}
+	}
+	arpTable := GetARPTable()
+
+	var results []ScanResult
+	for ipStr := range arpTable {
+		if ip := net.ParseIP(ipStr).To4(); ip != nil && p.contains(ip) {
+			results = append(results, ScanResult{IP: ip, Method: "ARP", Target: p.names[ipStr]})
+		}
+	}
+	results = p.addHeard(results, heard)
+
+	if gw, err := DefaultGateway(); err == nil {
+		results = markGateway(ctx, results, gw, p.opts, false)
+	}
+	opts := EnrichOptions{
+		SkipDNS:     true,
+		SkipVendor:  p.opts.SkipVendor,
+		Labels:      p.opts.Labels,
+		DeviceRules: p.opts.DeviceRules,
+		Vendors:     p.opts.Vendors,
+	}
+	for i := range results {
+		enrichOne(ctx, &results[i], arpTable, nil, opts) // no Reachability: nothing was probed to compare with
+	}
+	applyHeard(results, heard)
+	joinNeighbors6(results, GetNeighbors6())
+	if p.opts.IncludeSelf && len(p.opts.Targets) == 0 {
+		self := p.Interface.SelfResult()
+		applyLabel(&self, p.opts.Labels)
+		results = append(results, self)
+	}
+	for i := range results {
+		p.hook(&results[i])
+	}
+	SortResults(results)
+
+	// Nothing is probed, so there is no partial coverage to report
+	total := p.Count()
+	return &Report{Results: results, Total: total, Scanned: total, Warnings: warnings}, nil
+}
+
+// contains reports whether ip is one of the plan's hosts.
+func (p *Plan) contains(ip net.IP) bool {
+	for _, s := range p.sources {
+		if s.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
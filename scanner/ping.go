@@ -1,24 +1,108 @@
 package scanner
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
+	"os"
 	"os/exec"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// ScanResult holds information about a discovered host.
+// ScanResult holds information about a discovered host. Fields may be
+// added in minor releases (see Version), but not removed or renamed.
 type ScanResult struct {
-	IP        net.IP
-	Hostname  string
-	MAC       string
-	Vendor    string
-	Method    string // Detection method: ICMP, TCP, UDP, ARP
-	OpenPorts []int  // TCP ports that are open (accepted connection)
-	Status    string // Diff status: "NEW", "GONE", or "" (continuing)
+	IP         net.IP
+	Hostname   string
+	MAC        string
+	Vendor     string
+	Method     string   // Detection method: ICMP, TCP, UDP, ARP, SELF
+	OpenPorts  []int    // TCP ports that are open (accepted connection)
+	Status     string   // "NEW", "GONE", "CHANGED" (-diff), "MISSING" (-expected), or "" (continuing)
+	Role       string   // Notable role in the network, e.g. "this host", "gateway"
+	Target     string   // Hostname target this IP was resolved from, if any
+	Redirect   string   // Host that HTTP on port 80 redirected to, if any
+	RandomMAC  bool     // MAC is randomized (locally administered)
+	DeviceName string   // Name advertised over AirPlay/Bonjour, if any
+	Model      string   // Device model advertised over AirPlay, e.g. "AppleTV6,2"
+	Services   []string // Bonjour service types advertised, e.g. "_ipp._tcp"
+	Label      string   // user-assigned name matched by MAC (see LoadLabels)
+	DeviceType string   // device class from vendor and port rules, e.g. "IP camera"
+	Confidence int      // 0-100 certainty that the host is real (see scoreConfidence)
+	Unexpected bool     // not on the -expected devices list (see CheckExpected)
+
+	// RTT is the time the fastest TCP port took to accept or refuse a
+	// connection in connect mode, roughly one round trip (see
+	// LatencyBucket). It is zero if no TCP port answered.
+	RTT time.Duration
+
+	// Answers counts the replies each detection method got, e.g.
+	// {"TCP": 3, "ICMP": 1}, for scoreConfidence. Every TCP port that
+	// accepted or refused a connection is one answer. Methods skipped once
+	// the host was found are missing.
+	Answers map[string]int
+
+	// Banners maps open TCP ports to the first line their service sent,
+	// when banner grabbing is enabled (ScanOptions.Banners).
+	Banners map[int]string
+
+	// PortChanges lists the TCP ports that opened or closed since the
+	// previous scan, for hosts that are CHANGED in diff mode (see
+	// ComputeDiff). It is nil when the open ports are the same.
+	PortChanges *PortDiff
+
+	// Addresses lists every address of a dual-stack host, the IPv4 IP
+	// first and then IPv6 addresses with the same MAC from the neighbor
+	// cache. It is nil when no IPv6 address is known.
+	Addresses []net.IP
+
+	// SNMP is the community an SNMP agent answered, when communities are
+	// audited (ScanOptions.SNMPCommunities).
+	SNMP *SNMPInfo
+
+	// RTSP is set when a port answered an RTSP OPTIONS request during
+	// banner grabbing, which marks a camera or streaming device.
+	RTSP *RTSPInfo
+
+	// ClosedPorts are TCP ports that actively refused the connection: the
+	// host is up but nothing listens there. Only set with RecordClosed.
+	ClosedPorts []int
+
+	// FirstSeen and LastSeen come from the seen store (see JoinSeen) and
+	// are zero if it was not consulted.
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	// Extra holds fields added by an enrichment hook (EnrichOptions.Hook),
+	// such as an asset tag from an inventory database. localscan never
+	// sets it itself.
+	Extra map[string]string
+
+	// Instances are the Bonjour service instances behind Services, with
+	// their port, host and TXT metadata (ScanOptions.MDNSServices).
+	Instances []ServiceInstance
+
+	// Reachability compares the probe result with the ARP table: one of
+	// ReachLocal, ReachARPOnly, ReachRouted or ReachNoARP, or "" if it
+	// does not apply (the scanning host, passive mode).
+	Reachability string
+}
+
+// HostError records a notable probe failure for a host that was not found,
+// distinguishing "could not probe" from "not present".
+type HostError struct {
+	IP  net.IP
+	Err string
 }
 
 // Progress reports scan progress via a channel.
@@ -54,33 +138,260 @@ var udpPorts = []int{
 	123,   // NTP
 }
 
+// prober holds the per-scan probe settings derived from ScanOptions.
+type prober struct {
+	timeout     time.Duration
+	tcpMode     string
+	tcpPorts    []int
+	udpPorts    []int
+	udpPayloads map[int][]byte
+	udpMatches  map[int][]byte  // bytes a reply must contain to count
+	order       []string        // probe methods in the order they are tried
+	source      net.IP          // local address probes are sent from (OS default if nil)
+	echoed      map[string]bool // hosts that already answered a broadcast ping
+	banners     bool            // read a banner from each open TCP port
+	aliveOnly   bool            // stop the TCP probe once a port answers
+	jitter      float64         // fraction probe timeouts vary by at random
+	tcpPayloads map[int][]byte  // banner nudges that override bannerPayloads
+	snmpRequest []byte          // UDP probe payload for port 161
+	readBufs    *sync.Pool      // reply buffers of ScanOptions.ReadLimit bytes
+
+	// portTimeout replaces timeout for the TCP ports in it.
+	portTimeout map[int]time.Duration
+
+	// hostPorts replaces tcpPorts for the hosts in it.
+	hostPorts map[string][]int
+
+	cache *ProbeCache // full probe results of earlier scans, if any
+
+	// Dialers are configured once per scan rather than per probe.
+	tcpDialer *net.Dialer
+	udpDialer *net.Dialer
+
+	sent probeCounters // traffic estimate, shared across workers
+
+	// ICMP statistics for adaptive ordering, shared across workers.
+	icmpTried int64
+	icmpOK    int64
+	found     int64
+}
+
+// UDPProbe is a custom UDP probe: a host counts as running the service on
+// Port only if its reply to Payload contains Match.
+type UDPProbe struct {
+	Port    int
+	Payload []byte
+	Match   []byte
+}
+
+func newProber(opts ScanOptions) *prober {
+	p := &prober{
+		timeout:     opts.Timeout,
+		tcpMode:     opts.TCPMode,
+		tcpPorts:    tcpPorts,
+		udpPorts:    udpPorts,
+		udpPayloads: make(map[int][]byte),
+		udpMatches:  make(map[int][]byte),
+		order:       opts.MethodOrder,
+		source:      opts.SourceIP,
+		hostPorts:   opts.hostPorts,
+		banners:     opts.Banners,
+		aliveOnly:   opts.DiscoveryOnly,
+		jitter:      min(opts.TimeoutJitter, MaxTimeoutJitter),
+		portTimeout: opts.PortTimeouts,
+		cache:       opts.Cache,
+		tcpPayloads: opts.TCPPayloads,
+	}
+	community := DefaultSNMPCommunity
+	if len(opts.SNMPCommunities) > 0 {
+		community = opts.SNMPCommunities[0]
+	}
+	p.snmpRequest = snmpGet(opts.SNMPVersion, community)
+	readLimit := opts.ReadLimit
+	if readLimit <= 0 {
+		readLimit = DefaultReadLimit
+	}
+	p.readBufs = &sync.Pool{New: func() any {
+		buf := make([]byte, readLimit)
+		return &buf
+	}}
+	if len(p.order) == 0 {
+		p.order = DefaultMethodOrder
+	}
+	if len(opts.TCPPorts) > 0 {
+		p.tcpPorts = opts.TCPPorts
+	}
+	if len(opts.UDPPorts) > 0 {
+		p.udpPorts = opts.UDPPorts
+	}
+	for port, payload := range opts.UDPPayloads {
+		p.udpPayloads[port] = payload
+	}
+	for _, probe := range opts.UDPProbes {
+		p.udpPayloads[probe.Port] = probe.Payload
+		p.udpMatches[probe.Port] = probe.Match
+	}
+	// Ports with a custom payload are probed even if not in the default list.
+	for port := range p.udpPayloads {
+		if !containsPort(p.udpPorts, port) {
+			p.udpPorts = append(append([]int(nil), p.udpPorts...), port)
+		}
+	}
+
+	// Probe connections are closed right away, so TCP keep-alive is never
+	// needed; disabling it saves the setsockopt calls on every dial. (Go
+	// already disables Nagle's algorithm on TCP connections.)
+	p.tcpDialer = &net.Dialer{Timeout: p.timeout, KeepAlive: -1}
+	p.udpDialer = &net.Dialer{Timeout: p.timeout}
+	if p.source != nil {
+		p.tcpDialer.LocalAddr = &net.TCPAddr{IP: p.source}
+		p.udpDialer.LocalAddr = &net.UDPAddr{IP: p.source}
+	}
+	return p
+}
+
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
 // Scan performs a multi-method scan on all hosts:
 // 1. ICMP ping (system command)
 // 2. TCP connect probe
 // 3. UDP probe
 // Then checks ARP table for additional hosts that responded at L2 but not L3+.
-func Scan(hosts []net.IP, workers int, timeout time.Duration, progressCh chan<- Progress) []ScanResult {
+// Notable probe errors (e.g. no route to host) are returned for hosts that
+// were not found.
+func Scan(hosts []net.IP, workers int, timeout time.Duration, progressCh chan<- Progress) ([]ScanResult, []HostError) {
+	return ScanContext(context.Background(), hosts, workers, timeout, progressCh)
+}
+
+// ScanContext is Scan, stopping early once ctx is done (see
+// ScanGroupsContext).
+func ScanContext(ctx context.Context, hosts []net.IP, workers int, timeout time.Duration, progressCh chan<- Progress) ([]ScanResult, []HostError) {
+	return ScanGroupsContext(ctx, [][]net.IP{hosts}, ScanOptions{Workers: workers, Timeout: timeout}, progressCh)
+}
+
+// ScanGroups scans several host groups (e.g. disjoint subnets) with a single
+// bounded worker pool. Jobs are taken from the groups in round-robin order so
+// all subnets progress concurrently, and progress is reported against the
+// combined host count. An IP that appears in more than one group is probed once.
+// Only the probe-related fields of opts are used. progressCh may be nil;
+// otherwise it must be read until ScanGroups returns.
+func ScanGroups(groups [][]net.IP, opts ScanOptions, progressCh chan<- Progress) ([]ScanResult, []HostError) {
+	return ScanGroupsContext(context.Background(), groups, opts, progressCh)
+}
+
+// ScanGroupsContext is ScanGroups, stopping early once ctx is done: no new
+// hosts are started, probes in flight are cut short, and progress updates
+// progressCh is not ready for are dropped, so a caller may cancel ctx and
+// stop reading progress without the scan blocking. The hosts found until
+// then are returned.
+func ScanGroupsContext(ctx context.Context, groups [][]net.IP, opts ScanOptions, progressCh chan<- Progress) ([]ScanResult, []HostError) {
+	sources := make([]hostSource, len(groups))
+	for i, g := range groups {
+		sources[i] = newListSource(g)
+	}
+	results, hostErrs, _, _, _ := scanSources(ctx, sources, opts, nil, progressCh, nil)
+	return results, hostErrs
+}
+
+// scanSources runs the worker pool over hosts pulled lazily from sources,
+// so memory use does not grow with the size of the scanned range.
+// When ctx is done no new hosts are started, and the probes in flight are
+// cut short: their dials and reads fail and pings are killed. Hosts cut
+// short without being found do not count as probed. The ARP table is still
+// read once. It returns how many hosts were probed and an estimate of the
+// probe traffic sent.
+//
+// If the network goes down mid-scan (a run of hosts failing with "network
+// is unreachable" or similar), feeding pauses until a route is back and the
+// failed hosts are probed again. If it does not come back, no more hosts
+// are started and an error wrapping ErrNetworkDown is returned.
+//
+// With ScanOptions.MaxHosts, no new hosts are started once that many are
+// found, the probes in flight are cut short as if ctx were done, and hosts
+// that still answer are left out, so at most MaxHosts are reported. The
+// probing and ARP settling stop, but not ctx, and if hosts were left an
+// error wrapping ErrHostLimit is returned.
+//
+// If emit is non-nil, each found host is passed to it from the worker that
+// found it (so emit must be safe for concurrent use) instead of being kept,
+// and probe errors are not collected; the returned slices are then empty.
+//
+// Once ctx is done, progress updates that progressCh is not ready to take
+// are dropped rather than waited on, so a caller that cancels ctx may stop
+// reading progress without the scan blocking forever.
+func scanSources(ctx context.Context, sources []hostSource, opts ScanOptions, echoed map[string]bool, progressCh chan<- Progress, emit func(ScanResult)) ([]ScanResult, []HostError, int, ProbeStats, error) {
+	opts = opts.withDefaults()
+	limit := opts.MaxHosts
+	limitErr := fmt.Errorf("%w: %d hosts found", ErrHostLimit, limit)
+	outer := ctx // progress is still delivered once the limit stops probing
+	ctx, stopAtLimit := context.WithCancelCause(ctx)
+	defer stopAtLimit(nil)
+	pr := newProber(opts)
+	pr.echoed = echoed
+	total := countHosts(sources)
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = AutoWorkers(total)
+	}
+
+	if progressCh == nil {
+		ch := make(chan Progress, workers)
+		defer close(ch)
+		go func() {
+			for range ch {
+			}
+		}()
+		progressCh = ch
+	}
+
+	report := func(p Progress) {
+		select {
+		case progressCh <- p:
+			return
+		default:
+		}
+		select {
+		case progressCh <- p:
+		case <-outer.Done():
+		}
+	}
+
 	var (
 		mu       sync.Mutex
 		foundSet = make(map[string]bool)
+		errSet   = make(map[string]string)
 		results  []ScanResult
 		wg       sync.WaitGroup
 		progress int64
+		watch    = newNetWatch()
+		downErr  error
 	)
 
-	jobs := make(chan int, len(hosts))
-	total := len(hosts)
+	jobs := make(chan net.IP, workers)
 
 	// Start workers
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for idx := range jobs {
-				ip := hosts[idx]
+			for ip := range jobs {
+				if ctx.Err() != nil {
+					continue // drain the hosts queued before ctx was done
+				}
 				ipStr := ip.String()
 
-				method, openPorts := detectHost(ipStr, timeout)
+				method, ports, probeErr := pr.probeHost(ctx, ipStr)
+				if method == "" && ctx.Err() != nil {
+					continue // cut short, so neither found nor fully probed
+				}
+				watch.observe(ip, method != "", probeErr)
 
 				cur := int(atomic.AddInt64(&progress, 1))
 				p := Progress{
@@ -91,23 +402,63 @@ func Scan(hosts []net.IP, workers int, timeout time.Duration, progressCh chan<-
 
 				if method != "" {
 					mu.Lock()
-					if !foundSet[ipStr] {
+					if !foundSet[ipStr] && (limit <= 0 || len(foundSet) < limit) {
 						foundSet[ipStr] = true
-						result := ScanResult{IP: cloneIP(ip), Method: method, OpenPorts: openPorts}
-						results = append(results, result)
+						if len(foundSet) == limit {
+							stopAtLimit(limitErr)
+						}
+						result := ScanResult{IP: cloneIP(ip), Method: method, OpenPorts: ports.open, Banners: ports.banners, RTSP: ports.rtsp, RTT: ports.rtt, Answers: ports.answers}
+						if _, own := pr.hostPorts[ipStr]; own || opts.RecordClosed {
+							result.ClosedPorts = ports.closed
+						}
+						if emit == nil {
+							results = append(results, result)
+						}
 						p.Found = &result
 					}
 					mu.Unlock()
+					if emit != nil && p.Found != nil {
+						emit(*p.Found)
+					}
+				} else if probeErr != nil && emit == nil {
+					mu.Lock()
+					errSet[ipStr] = probeErr.Error()
+					mu.Unlock()
 				}
 
-				progressCh <- p
+				report(p)
 			}
 		}()
 	}
 
-	// Send jobs
-	for i := range hosts {
-		jobs <- i
+	// Send jobs until done or cancelled, waiting out network outages
+	var send func(ip net.IP) bool
+	send = func(ip net.IP) bool {
+		for {
+			select {
+			case jobs <- ip:
+				return true
+			case <-ctx.Done():
+				return false
+			case <-watch.down:
+				retry, err := watch.wait(ctx)
+				if err != nil {
+					downErr = err
+					return false
+				}
+				atomic.AddInt64(&progress, -int64(len(retry)))
+				for _, r := range retry {
+					if !send(r) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	for ip := range interleave(sources) {
+		if !send(ip) {
+			break
+		}
 	}
 	close(jobs)
 	wg.Wait()
@@ -115,108 +466,574 @@ func Scan(hosts []net.IP, workers int, timeout time.Duration, progressCh chan<-
 	// Phase 2: Check ARP table for hosts that responded to ARP but not to probes.
 	// Our probe attempts triggered ARP resolution, so the OS ARP cache now
 	// contains entries even for hosts that didn't respond to TCP/UDP/ICMP.
-	arpTable := GetARPTable()
-	for _, ip := range hosts {
-		ipStr := ip.String()
-		if foundSet[ipStr] {
+	arpTable := settleARPTable(ctx, opts.ARPSettle)
+	for ipStr, mac := range arpTable {
+		ip := net.ParseIP(ipStr).To4()
+		if ip == nil || foundSet[ipStr] || mac == "" || !anyContains(sources, ip) {
 			continue
 		}
-		if mac, ok := arpTable[ipStr]; ok && mac != "" {
-			result := ScanResult{IP: cloneIP(ip), Method: "ARP"}
+		if limit > 0 && len(foundSet) >= limit {
+			break
+		}
+		foundSet[ipStr] = true
+		result := ScanResult{IP: ip, Method: "ARP"}
+		if emit != nil {
+			emit(result)
+		} else {
 			results = append(results, result)
-			progressCh <- Progress{
-				Current: total,
-				Total:   total,
-				IP:      ipStr,
-				Found:   &result,
+		}
+		report(Progress{
+			Current: total,
+			Total:   total,
+			IP:      ipStr,
+			Found:   &result,
+		})
+	}
+
+	var hostErrs []HostError
+	for ipStr, msg := range errSet {
+		if !foundSet[ipStr] {
+			hostErrs = append(hostErrs, HostError{IP: net.ParseIP(ipStr).To4(), Err: msg})
+		}
+	}
+	sort.Slice(hostErrs, func(i, j int) bool {
+		return ipToUint32(hostErrs[i].IP) < ipToUint32(hostErrs[j].IP)
+	})
+
+	scanned := int(atomic.LoadInt64(&progress))
+	if downErr == nil && errors.Is(context.Cause(ctx), ErrHostLimit) && scanned < total {
+		downErr = limitErr
+	}
+	return results, hostErrs, scanned, pr.sent.stats(), downErr
+}
+
+// ErrHostLimit is returned when a scan stopped early because it found
+// ScanOptions.MaxHosts hosts.
+var ErrHostLimit = errors.New("host limit reached")
+
+// arpRereads is how many extra times the ARP table is read during the settle time.
+const arpRereads = 3
+
+// settleARPTable reads the ARP table, then re-reads it a few times over the
+// settle duration to pick up resolutions that completed after the last
+// probes were sent. Entries are merged; the first MAC seen for an IP wins.
+func settleARPTable(ctx context.Context, settle time.Duration) map[string]string {
+	table := GetARPTable()
+	if settle <= 0 {
+		return table
+	}
+	interval := settle / arpRereads
+	for i := 0; i < arpRereads; i++ {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return table
+		}
+		for ip, mac := range GetARPTable() {
+			if _, ok := table[ip]; !ok {
+				table[ip] = mac
 			}
 		}
 	}
+	return table
+}
+
+// portStates lists the TCP ports that accepted a connection (open) and
+// those that actively refused it (closed). Ports that did not answer at all
+// are filtered and appear in neither list.
+type portStates struct {
+	open    []int
+	closed  []int
+	banners map[int]string // first line sent by open ports (see grabBanner)
+	rtsp    *RTSPInfo      // RTSP server found while grabbing banners
+	rtt     time.Duration  // fastest TCP answer, open or refused
+	answers map[string]int // replies per detection method (ScanResult.Answers)
+}
+
+// answered records n replies to method.
+func (s *portStates) answered(method string, n int) {
+	if n <= 0 {
+		return
+	}
+	if s.answers == nil {
+		s.answers = make(map[string]int)
+	}
+	s.answers[method] += n
+}
+
+// Probe methods for ScanOptions.MethodOrder.
+const (
+	MethodICMP = "icmp"
+	MethodTCP  = "tcp"
+	MethodUDP  = "udp"
+)
+
+// DefaultMethodOrder is the probe order used when none is configured.
+var DefaultMethodOrder = []string{MethodICMP, MethodTCP, MethodUDP}
+
+// ParseMethodOrder parses a comma-separated probe order such as
+// "tcp,icmp,udp". Methods left out are not used.
+func ParseMethodOrder(s string) ([]string, error) {
+	var order []string
+	for _, m := range strings.Split(s, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		switch m {
+		case MethodICMP, MethodTCP, MethodUDP:
+		default:
+			return nil, fmt.Errorf("unknown probe method %q (use icmp, tcp, udp)", m)
+		}
+		if slices.Contains(order, m) {
+			return nil, fmt.Errorf("probe method %q listed twice", m)
+		}
+		order = append(order, m)
+	}
+	return order, nil
+}
 
-	return results
+// ICMP is moved to the end of the probe order once this many hosts were
+// pinged without a single reply while other methods kept finding hosts,
+// since the network evidently filters ICMP.
+const (
+	icmpAdaptAfter = 32
+	icmpAdaptFound = 3
+)
+
+// probeOrder returns the method order for the next host, with ICMP
+// deprioritized if it has proven useless so far.
+func (p *prober) probeOrder() []string {
+	if atomic.LoadInt64(&p.icmpOK) > 0 ||
+		atomic.LoadInt64(&p.icmpTried) < icmpAdaptAfter ||
+		atomic.LoadInt64(&p.found) < icmpAdaptFound {
+		return p.order
+	}
+	i := slices.Index(p.order, MethodICMP)
+	if i < 0 || i == len(p.order)-1 {
+		return p.order
+	}
+	order := slices.Delete(slices.Clone(p.order), i, i+1)
+	return append(order, MethodICMP)
 }
 
 // detectHost tries each probe method in order and returns the name of
 // the first method that detected the host (or "" if none succeeded),
-// along with a list of open TCP ports.
-func detectHost(ip string, timeout time.Duration) (string, []int) {
-	icmpAlive := icmpPing(ip, timeout)
-	tcpAlive, openPorts := tcpProbe(ip, timeout)
+// along with the TCP port states and the first notable probe error.
+// The TCP probe always runs, even after another method succeeded, so that
+// open ports are collected; ICMP and UDP are skipped once the host is found.
+// With ScanOptions.DiscoveryOnly, no ports are collected: TCP is skipped
+// too once the host is found, and stops at the first port that answers.
+// When both ICMP and TCP are enabled they run concurrently. A host that
+// answered the broadcast ping counts as answering ICMP without a ping.
+// A host given with its own ports is probed on those over TCP alone.
+func (p *prober) detectHost(ctx context.Context, ip string) (string, portStates, error) {
+	return p.detect(ctx, ip, p.aliveOnly)
+}
 
-	if icmpAlive {
-		return "ICMP", openPorts
+// detect is detectHost, collecting no ports if aliveOnly is set. A host
+// given with its own ports is only probed over TCP, on all of those ports,
+// whatever the method order and aliveOnly.
+func (p *prober) detect(ctx context.Context, ip string, aliveOnly bool) (string, portStates, error) {
+	if _, own := p.hostPorts[ip]; own {
+		return p.detectOwnPorts(ctx, ip)
+	}
+
+	var (
+		method   string
+		ports    portStates
+		tcpErr   error
+		udpErr   error
+		pairDone bool
+		icmpOK   bool
+		udpOK    bool
+	)
+	order := p.probeOrder()
+	echoed := p.echoed[ip]
+	parallel := !echoed && slices.Contains(order, MethodICMP) && slices.Contains(order, MethodTCP)
+	for _, m := range order {
+		switch {
+		case parallel && (m == MethodICMP || m == MethodTCP):
+			if pairDone {
+				continue // the pair already ran at the earlier of the two
+			}
+			pairDone = true
+			var found string
+			found, ports, tcpErr = p.icmpAndTCP(ctx, ip, order, aliveOnly)
+			if method == "" {
+				method = found
+			}
+		case m == MethodICMP:
+			if method == "" && (echoed || p.ping(ctx, ip)) {
+				method, icmpOK = "ICMP", true
+			}
+		case m == MethodTCP:
+			if aliveOnly && method != "" {
+				continue
+			}
+			var alive bool
+			alive, ports, tcpErr = p.tcpProbe(ctx, ip, aliveOnly)
+			if alive && method == "" {
+				method = "TCP"
+			}
+		case m == MethodUDP:
+			if method != "" {
+				continue
+			}
+			var alive bool
+			if alive, udpErr = p.udpProbe(ctx, ip); alive {
+				method, udpOK = "UDP", true
+			}
+		}
+	}
+
+	if icmpOK {
+		ports.answered("ICMP", 1)
+	}
+	if udpOK {
+		ports.answered("UDP", 1)
 	}
+	if aliveOnly {
+		ports = portStates{rtt: ports.rtt, answers: ports.answers} // only as many as it took to answer
+	}
+	if method != "" {
+		atomic.AddInt64(&p.found, 1)
+		return method, ports, nil
+	}
+	if tcpErr != nil {
+		return "", portStates{}, tcpErr
+	}
+	return "", portStates{}, udpErr
+}
+
+// detectOwnPorts probes a host given as ip:port on its ports alone. No
+// ping or UDP probe is sent, but an answer to the broadcast ping still
+// counts as ICMP.
+func (p *prober) detectOwnPorts(ctx context.Context, ip string) (string, portStates, error) {
+	alive, ports, err := p.tcpProbe(ctx, ip, false)
+	method := ""
+	switch {
+	case alive:
+		method = "TCP"
+	case p.echoed[ip]:
+		method = "ICMP"
+	}
+	if method == "" {
+		return "", portStates{}, err
+	}
+	if p.echoed[ip] {
+		ports.answered("ICMP", 1)
+	}
+	atomic.AddInt64(&p.found, 1)
+	return method, ports, nil
+}
+
+// icmpAndTCP pings the host while the TCP probe runs. Once TCP confirms
+// the host, a ping that has not answered yet is cancelled instead of being
+// waited out; a ping that answers first still waits for TCP so that open
+// ports are collected. If both succeed, the method listed first in order
+// is reported.
+func (p *prober) icmpAndTCP(ctx context.Context, ip string, order []string, aliveOnly bool) (string, portStates, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	icmpCh := make(chan bool, 1) // buffered so the goroutine never blocks
+	go func() {
+		icmpCh <- p.ping(ctx, ip)
+	}()
+
+	tcpAlive, ports, tcpErr := p.tcpProbe(ctx, ip, aliveOnly)
+	icmpAlive := false
 	if tcpAlive {
-		return "TCP", openPorts
+		select {
+		case icmpAlive = <-icmpCh:
+		default:
+			cancel()
+		}
+	} else {
+		icmpAlive = <-icmpCh
+	}
+	if icmpAlive {
+		ports.answered("ICMP", 1)
+	}
+
+	switch {
+	case icmpAlive && tcpAlive:
+		if slices.Index(order, MethodTCP) < slices.Index(order, MethodICMP) {
+			return "TCP", ports, nil
+		}
+		return "ICMP", ports, nil
+	case icmpAlive:
+		return "ICMP", ports, nil
+	case tcpAlive:
+		return "TCP", ports, nil
 	}
-	if udpProbe(ip, timeout) {
-		return "UDP", openPorts
+	return "", ports, tcpErr
+}
+
+// ping runs icmpPing and records the outcome for adaptive ordering.
+// Pings cut short by ctx are not counted.
+func (p *prober) ping(ctx context.Context, ip string) bool {
+	p.sent.icmpEcho()
+	alive := icmpPing(ctx, ip, p.probeTimeout(), p.source)
+	if ctx.Err() == nil {
+		atomic.AddInt64(&p.icmpTried, 1)
+		if alive {
+			atomic.AddInt64(&p.icmpOK, 1)
+		}
 	}
-	return "", nil
+	return alive
 }
 
 // icmpPing uses the system ping command (no root required on macOS/Linux).
-func icmpPing(ip string, timeout time.Duration) bool {
+// The command is killed if ctx is cancelled. A non-nil source sets the
+// address the echo request is sent from.
+func icmpPing(ctx context.Context, ip string, timeout time.Duration, source net.IP) bool {
 	timeoutSec := int(timeout.Milliseconds())
 	if timeoutSec < 1 {
 		timeoutSec = 1
 	}
 
-	var cmd *exec.Cmd
+	var args []string
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("ping", "-n", "1", "-w", fmt.Sprintf("%d", timeoutSec), ip)
+		args = []string{"-n", "1", "-w", fmt.Sprintf("%d", timeoutSec)}
+		if source != nil {
+			args = append(args, "-S", source.String())
+		}
 	case "darwin":
-		cmd = exec.Command("ping", "-c", "1", "-W", fmt.Sprintf("%d", timeoutSec), ip)
+		args = []string{"-c", "1", "-W", fmt.Sprintf("%d", timeoutSec)}
+		if source != nil {
+			args = append(args, "-S", source.String())
+		}
 	default: // linux
-		cmd = exec.Command("ping", "-c", "1", "-W", fmt.Sprintf("%d", max(1, timeoutSec/1000)), ip)
+		args = []string{"-c", "1", "-W", fmt.Sprintf("%d", max(1, timeoutSec/1000))}
+		if source != nil {
+			args = append(args, "-I", source.String())
+		}
 	}
+	cmd := exec.CommandContext(ctx, "ping", append(args, ip)...)
 
 	err := cmd.Run()
 	return err == nil
 }
 
+// TCP probe modes.
+const (
+	TCPConnect = "connect" // full handshake via the OS (default, unprivileged)
+	TCPSYN     = "syn"     // half-open scan via raw sockets (privileged)
+)
+
+// CheckTCPMode reports whether mode is a known TCP probe mode that can be
+// used on this system. SYN mode needs raw socket privileges.
+func CheckTCPMode(mode string) error {
+	switch mode {
+	case "", TCPConnect:
+		return nil
+	case TCPSYN:
+		return synAvailable()
+	default:
+		return fmt.Errorf("unknown TCP mode %q (use connect or syn)", mode)
+	}
+}
+
 // tcpProbe tries to connect to common ports on the given IP.
 // Returns true if any port responds (open or refused = host alive),
-// and a list of ports that accepted connections (open).
-func tcpProbe(ip string, timeout time.Duration) (bool, []int) {
+// a list of ports that accepted connections (open), and the first
+// notable error encountered. If untilAlive is set, the remaining ports
+// are skipped once one responds, so the port lists are incomplete; SYN
+// mode sends to every port at once regardless. A host given with its own
+// ports is probed on those alone, and always on all of them. Once ctx is
+// done, the remaining ports are skipped and a pending connect or banner
+// read is abandoned.
+func (p *prober) tcpProbe(ctx context.Context, ip string, untilAlive bool) (bool, portStates, error) {
+	tcpPorts, own := p.hostPorts[ip]
+	if own {
+		untilAlive = false
+	} else {
+		tcpPorts = p.tcpPorts
+	}
+
+	var notable error
+	if p.tcpMode == TCPSYN {
+		alive, ports, err := synProbe(ip, tcpPorts, p.synTimeout(tcpPorts), p.source)
+		if err == nil {
+			p.sent.rawSYNs(len(tcpPorts))
+			ports.answered("TCP", len(ports.open)+len(ports.closed))
+			return alive, ports, nil
+		}
+		// Fall back to connect scan if the raw send fails for this host.
+		notable = fmt.Errorf("tcp syn: %w", err)
+	}
+
 	alive := false
-	var openPorts []int
+	var ports portStates
 	for _, port := range tcpPorts {
-		addr := fmt.Sprintf("%s:%d", ip, port)
-		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if ctx.Err() != nil {
+			break
+		}
+		addr := net.JoinHostPort(ip, strconv.Itoa(port))
+		begin := time.Now()
+		conn, err := p.dial(ctx, "tcp", addr, p.tcpTimeout(port))
+		p.sent.tcpDial(err == nil)
+		if rtt := time.Since(begin); (err == nil || isConnRefused(err)) && (ports.rtt == 0 || rtt < ports.rtt) {
+			ports.rtt = rtt
+		}
 		if err == nil {
-			conn.Close()
+			if p.banners {
+				stop := closeOnDone(ctx, conn)
+				banner, rtsp := p.grabBanner(conn, port)
+				stop()
+				if banner != "" {
+					if ports.banners == nil {
+						ports.banners = make(map[int]string)
+					}
+					ports.banners[port] = banner
+				}
+				if rtsp != nil && ports.rtsp == nil {
+					ports.rtsp = rtsp
+				}
+			}
+			closeReset(conn)
 			alive = true
-			openPorts = append(openPorts, port)
+			ports.open = append(ports.open, port)
+			if untilAlive {
+				break
+			}
 			continue
 		}
 		if isConnRefused(err) {
 			alive = true
+			ports.closed = append(ports.closed, port)
+			if untilAlive {
+				break
+			}
+		} else if notable == nil && isNotableErr(err) {
+			notable = fmt.Errorf("tcp: %w", unwrapSyscallErr(err))
 		}
 	}
-	return alive, openPorts
+	ports.answered("TCP", len(ports.open)+len(ports.closed))
+	return alive, ports, notable
+}
+
+// ProbeTCP connect-scans ports on ip (the built-in list if ports is empty)
+// and reports whether the host answered, by accepting or refusing a
+// connection, along with the open ports.
+func ProbeTCP(ip string, ports []int, timeout time.Duration) (bool, []int) {
+	p := newProber(ScanOptions{Timeout: timeout, TCPPorts: ports}.withDefaults())
+	alive, states, _ := p.tcpProbe(context.Background(), ip, false)
+	return alive, states.open
+}
+
+// ProbeUDP sends the discovery probe for each of ports (the built-in list
+// if empty) to ip and reports whether a valid reply came back.
+func ProbeUDP(ip string, ports []int, timeout time.Duration) bool {
+	p := newProber(ScanOptions{Timeout: timeout, UDPPorts: ports}.withDefaults())
+	alive, _ := p.udpProbe(context.Background(), ip)
+	return alive
 }
 
 // udpProbe sends UDP packets to common discovery ports.
 // A response or ICMP port-unreachable (which won't error on some OSes)
 // indicates the host is alive.
-func udpProbe(ip string, timeout time.Duration) bool {
-	for _, port := range udpPorts {
-		if udpCheck(ip, port, timeout) {
-			return true
+func (p *prober) udpProbe(ctx context.Context, ip string) (bool, error) {
+	var notable error
+	for _, port := range p.udpPorts {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := p.udpCheck(ctx, ip, port)
+		if ok {
+			return true, nil
+		}
+		if notable == nil && err != nil && isNotableErr(err) {
+			notable = fmt.Errorf("udp: %w", unwrapSyscallErr(err))
 		}
 	}
-	return false
+	return false, notable
+}
+
+// dial connects within timeout, from the configured source address if one
+// is set. It gives up early if ctx is done.
+func (p *prober) dial(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	d := p.udpDialer
+	if network == "tcp" {
+		d = p.tcpDialer
+	}
+	if timeout != d.Timeout {
+		jd := *d
+		jd.Timeout = timeout
+		d = &jd
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// probeTimeout returns the probe timeout, varied at random by up to the
+// jitter fraction either way, so that probes sent together do not all
+// time out together and come back as a burst.
+func (p *prober) probeTimeout() time.Duration {
+	return p.jittered(p.timeout)
+}
+
+// tcpTimeout is probeTimeout for a TCP port, using its override in
+// ScanOptions.PortTimeouts if it has one.
+func (p *prober) tcpTimeout(port int) time.Duration {
+	if d, ok := p.portTimeout[port]; ok {
+		return p.jittered(d)
+	}
+	return p.probeTimeout()
+}
+
+// synTimeout is the time SYN mode waits for replies from all ports at
+// once: the longest of the probe timeout and the overrides for ports.
+func (p *prober) synTimeout(ports []int) time.Duration {
+	timeout := p.timeout
+	for _, port := range ports {
+		if d := p.portTimeout[port]; d > timeout {
+			timeout = d
+		}
+	}
+	return p.jittered(timeout)
+}
+
+// jittered varies d at random by up to the jitter fraction either way.
+func (p *prober) jittered(d time.Duration) time.Duration {
+	if p.jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + p.jitter*(2*rand.Float64()-1)))
+}
+
+// closeReset closes a probe connection with a reset instead of the usual
+// FIN handshake, so it does not linger in TIME_WAIT. Sweeping thousands
+// of hosts would otherwise tie up ephemeral ports for a minute or more.
+func closeReset(conn net.Conn) {
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// closeOnDone closes conn once ctx is done, so that a probe blocked reading
+// from it returns right away. The returned function stops that; call it
+// when done with conn.
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { conn.Close() })
 }
 
-func udpCheck(ip string, port int, timeout time.Duration) bool {
-	addr := fmt.Sprintf("%s:%d", ip, port)
-	conn, err := net.DialTimeout("udp", addr, timeout)
+// DefaultReadLimit is the default size of the buffers UDP replies and
+// banners are read into: one Ethernet frame.
+const DefaultReadLimit = 1500
+
+// MaxReadLimit is the largest useful read limit, the maximum UDP payload.
+const MaxReadLimit = 65507
+
+func (p *prober) udpCheck(ctx context.Context, ip string, port int) (bool, error) {
+	timeout := p.probeTimeout()
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+	conn, err := p.dial(ctx, "udp", addr, timeout)
 	if err != nil {
-		return false
+		return false, err
 	}
 	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
 
 	// Send a probe packet appropriate for the port
 	var payload []byte
@@ -227,22 +1044,88 @@ func udpCheck(ip string, port int, timeout time.Duration) bool {
 		payload = ssdpSearch()
 	case 137: // NetBIOS name query
 		payload = netbiosQuery()
-	case 161: // SNMP get-request (community: public)
-		payload = snmpGetRequest()
+	case 161: // SNMP get-request for sysDescr (community: public by default)
+		payload = p.snmpRequest
+	case 53: // DNS query for the root NS records
+		payload = dnsQuery()
+	case 123: // NTP client request
+		payload = ntpRequest()
 	default:
 		payload = []byte("\x00")
 	}
+	if custom, ok := p.udpPayloads[port]; ok {
+		payload = custom
+	}
 
 	conn.SetDeadline(time.Now().Add(timeout))
 	_, err = conn.Write(payload)
 	if err != nil {
-		return false
+		return false, err
 	}
+	p.sent.udpPacket(len(payload))
 
-	buf := make([]byte, 512)
+	bufp := p.readBufs.Get().(*[]byte)
+	defer p.readBufs.Put(bufp)
+	buf := *bufp
 	conn.SetDeadline(time.Now().Add(timeout))
 	n, err := conn.Read(buf)
-	return err == nil && n > 0
+	if err != nil {
+		return false, err
+	}
+	return p.validUDPReply(port, buf[:n]), nil
+}
+
+// validUDPReply reports whether reply shows a service listening on port.
+// Custom probes with a match pattern require the reply to contain it.
+// Replies to the built-in probes must be well-formed responses of that
+// protocol, so stray data or a misreported ICMP error does not count.
+func (p *prober) validUDPReply(port int, reply []byte) bool {
+	if match, ok := p.udpMatches[port]; ok {
+		return bytes.Contains(reply, match)
+	}
+	if _, custom := p.udpPayloads[port]; custom {
+		return len(reply) > 0
+	}
+	if valid, ok := udpValidators[port]; ok {
+		return valid(reply)
+	}
+	return len(reply) > 0
+}
+
+// udpValidators check replies to the built-in probe on each port.
+var udpValidators = map[int]func([]byte) bool{
+	5353: func(r []byte) bool { return isDNSResponse(r, 0x0000) },
+	53:   func(r []byte) bool { return isDNSResponse(r, dnsProbeID) },
+	137:  func(r []byte) bool { return isDNSResponse(r, 0x8001) }, // NetBIOS shares the DNS header
+	161:  isSNMPResponse,
+	1900: func(r []byte) bool { return bytes.HasPrefix(r, []byte("HTTP/1.")) },
+	123:  func(r []byte) bool { return len(r) >= 48 && r[0]&0x07 == 4 }, // mode 4: server
+}
+
+// isDNSResponse reports whether r is a DNS-style response (QR bit set)
+// carrying the transaction ID of the query.
+func isDNSResponse(r []byte, id uint16) bool {
+	return len(r) >= 12 &&
+		uint16(r[0])<<8|uint16(r[1]) == id &&
+		r[2]&0x80 != 0
+}
+
+// isSNMPResponse reports whether r is an SNMP GetResponse to the get-request
+// built by snmpGet.
+func isSNMPResponse(r []byte) bool {
+	requestID := []byte{0x02, 0x04, 0x00, 0x00, 0x00, 0x01}
+	return len(r) > 2 && r[0] == 0x30 &&
+		bytes.Contains(r, []byte{0xa2}) && bytes.Contains(r, requestID)
+}
+
+// unwrapSyscallErr strips the net.OpError wrapping so that only the
+// underlying reason (e.g. "no route to host") is reported.
+func unwrapSyscallErr(err error) error {
+	var sysErr *os.SyscallError
+	if errors.As(err, &sysErr) {
+		return sysErr.Err
+	}
+	return err
 }
 
 // mDNSQuery returns a minimal mDNS query packet.
@@ -293,23 +1176,29 @@ func netbiosQuery() []byte {
 	}
 }
 
-// snmpGetRequest returns a minimal SNMPv1 get-request (community: public).
-func snmpGetRequest() []byte {
+// dnsProbeID is the transaction ID of dnsQuery.
+const dnsProbeID = 0x4c53
+
+// dnsQuery returns a DNS query for the root NS records.
+func dnsQuery() []byte {
 	return []byte{
-		0x30, 0x26,
-		0x02, 0x01, 0x00, // version: SNMPv1
-		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c', // community: public
-		0xa0, 0x19, // GetRequest PDU
-		0x02, 0x04, 0x00, 0x00, 0x00, 0x01, // request-id
-		0x02, 0x01, 0x00, // error-status
-		0x02, 0x01, 0x00, // error-index
-		0x30, 0x0b, // varbind list
-		0x30, 0x09,
-		0x06, 0x05, 0x2b, 0x06, 0x01, 0x02, 0x01, // OID: 1.3.6.1.2.1 (system)
-		0x05, 0x00, // value: null
+		dnsProbeID >> 8, dnsProbeID & 0xff, // Transaction ID
+		0x01, 0x00, // Flags: recursion desired
+		0x00, 0x01, // Questions: 1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00,       // Query: . (root)
+		0x00, 0x02, // type NS
+		0x00, 0x01, // class IN
 	}
 }
 
+// ntpRequest returns an NTPv3 client request.
+func ntpRequest() []byte {
+	pkt := make([]byte, 48)
+	pkt[0] = 0x1b // LI 0, version 3, mode 3 (client)
+	return pkt
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -0,0 +1,77 @@
+//go:build linux
+
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// unansweredPort returns a loopback port whose SYNs go unanswered: it
+// listens with a backlog of 0 and fills the queue with one connection that
+// is never accepted, so Linux drops further SYNs.
+func unansweredPort(t *testing.T) int {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := sa.(*syscall.SockaddrInet4).Port
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return port
+}
+
+func TestProbeTCPTimeout(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+	begin := time.Now()
+	alive, ports := ProbeTCP("127.0.0.1", []int{unansweredPort(t)}, timeout)
+	elapsed := time.Since(begin)
+	if alive || len(ports) != 0 {
+		t.Errorf("ProbeTCP(unanswered) = %v %v, want false with no open ports", alive, ports)
+	}
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("ProbeTCP took %v, want about the %v timeout", elapsed, timeout)
+	}
+}
+
+// TestScanCancelAbandonsProbes checks that cancelling ctx stops a probe
+// that is still waiting on a connect, instead of waiting out its timeout,
+// and that the host does not count as scanned.
+func TestScanCancelAbandonsProbes(t *testing.T) {
+	opts := ScanOptions{
+		Workers:     1,
+		Timeout:     10 * time.Second,
+		MethodOrder: []string{MethodTCP},
+		TCPPorts:    []int{unansweredPort(t)},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	results, _, scanned, _, _ := scanSources(ctx, []hostSource{newListSource([]net.IP{net.IPv4(127, 0, 0, 1).To4()})}, opts, nil, nil, nil)
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("scan took %v after a 200ms deadline with a 10s probe timeout", elapsed)
+	}
+	if scanned != 0 || len(results) != 0 {
+		t.Errorf("scanned %d hosts, found %d, want none: the only probe was cut short", scanned, len(results))
+	}
+}
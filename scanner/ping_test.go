@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestScanCancelWithUnreadProgress scans with an unbuffered progress
+// channel whose reader takes a few updates slowly and then stops. Once ctx
+// is cancelled the scan must return instead of blocking forever on a
+// progress send.
+func TestScanCancelWithUnreadProgress(t *testing.T) {
+	var hosts []net.IP
+	for i := 1; i <= 32; i++ {
+		hosts = append(hosts, net.IPv4(127, 0, 0, byte(i)).To4())
+	}
+	opts := ScanOptions{
+		Workers:     4,
+		Timeout:     100 * time.Millisecond,
+		MethodOrder: []string{MethodTCP},
+		TCPPorts:    []int{freePort(t)},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	progressCh := make(chan Progress)
+	go func() {
+		for range 3 {
+			<-progressCh
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		ScanGroupsContext(ctx, [][]net.IP{hosts}, opts, progressCh)
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not return after ctx was cancelled with an unread progress channel")
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go acceptAndClose(ln)
+	open, closed := ln.Addr().(*net.TCPAddr).Port, freePort(t)
+
+	alive, ports := ProbeTCP("127.0.0.1", []int{closed, open}, time.Second)
+	if !alive || !slices.Equal(ports, []int{open}) {
+		t.Errorf("ProbeTCP(open, closed) = %v %v, want true [%d]", alive, ports, open)
+	}
+
+	// A refused connection still shows the host is up
+	alive, ports = ProbeTCP("127.0.0.1", []int{closed}, time.Second)
+	if !alive || len(ports) != 0 {
+		t.Errorf("ProbeTCP(closed) = %v %v, want true with no open ports", alive, ports)
+	}
+}
+
+// TestDetectDiscoveryOnlyHostPorts checks that -discovery-only still
+// reports the ports of a host given as ip:port, while other hosts get none.
+func TestDetectDiscoveryOnlyHostPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go acceptAndClose(ln)
+	open, closed := ln.Addr().(*net.TCPAddr).Port, freePort(t)
+
+	p := newProber(ScanOptions{
+		Timeout:       time.Second,
+		MethodOrder:   []string{MethodTCP},
+		TCPPorts:      []int{closed},
+		DiscoveryOnly: true,
+		hostPorts:     map[string][]int{"127.0.0.1": {closed, open}},
+	}.withDefaults())
+
+	method, ports, err := p.detect(context.Background(), "127.0.0.1", true)
+	if method != "TCP" || err != nil {
+		t.Fatalf("detect(127.0.0.1) = %q, %v", method, err)
+	}
+	if !slices.Equal(ports.open, []int{open}) || !slices.Equal(ports.closed, []int{closed}) {
+		t.Errorf("host ports = open %v closed %v, want open [%d] closed [%d]", ports.open, ports.closed, open, closed)
+	}
+
+	// 127.0.0.2 has no ports of its own, so the refused port only shows it is up
+	method, ports, _ = p.detect(context.Background(), "127.0.0.2", true)
+	if method != "TCP" || len(ports.open) != 0 || len(ports.closed) != 0 {
+		t.Errorf("detect(127.0.0.2) = %q open %v closed %v, want TCP with no ports", method, ports.open, ports.closed)
+	}
+}
+
+// TestDetectHostPortsTCPOnly checks that a host given as ip:port is probed
+// on its own ports over TCP alone, even when ICMP and UDP come first.
+func TestDetectHostPortsTCPOnly(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go acceptAndClose(ln)
+	open, closed := ln.Addr().(*net.TCPAddr).Port, freePort(t)
+
+	p := newProber(ScanOptions{
+		Timeout:       time.Second,
+		MethodOrder:   []string{MethodICMP, MethodUDP, MethodTCP},
+		DiscoveryOnly: true,
+		hostPorts:     map[string][]int{"127.0.0.1": {closed, open}},
+	}.withDefaults())
+
+	method, ports, err := p.detect(context.Background(), "127.0.0.1", true)
+	if method != "TCP" || err != nil {
+		t.Fatalf("detect(127.0.0.1) = %q, %v", method, err)
+	}
+	if !slices.Equal(ports.open, []int{open}) || !slices.Equal(ports.closed, []int{closed}) {
+		t.Errorf("host ports = open %v closed %v, want open [%d] closed [%d]", ports.open, ports.closed, open, closed)
+	}
+	if n := p.sent.icmp.Load() + p.sent.udp.Load(); n != 0 {
+		t.Errorf("sent %d ICMP and UDP probes, want none", n)
+	}
+	if ports.answers["TCP"] != 2 || len(ports.answers) != 1 {
+		t.Errorf("answers = %v, want 2 TCP answers only", ports.answers)
+	}
+}
+
+func TestProbeUDP(t *testing.T) {
+	echo, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go echoUDP(echo)
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	closed, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.LocalAddr().(*net.UDPAddr).Port
+	closed.Close()
+
+	port := func(c net.PacketConn) int { return c.LocalAddr().(*net.UDPAddr).Port }
+	const timeout = 300 * time.Millisecond
+	tests := []struct {
+		name string
+		port int
+		want bool
+	}{
+		{"open", port(echo), true},
+		{"closed", closedPort, false},
+		{"timeout", port(silent), false},
+	}
+	for _, tt := range tests {
+		begin := time.Now()
+		if got := ProbeUDP("127.0.0.1", []int{tt.port}, timeout); got != tt.want {
+			t.Errorf("ProbeUDP(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+		if elapsed := time.Since(begin); elapsed > timeout+time.Second {
+			t.Errorf("ProbeUDP(%s) took %v with a %v timeout", tt.name, elapsed, timeout)
+		}
+	}
+}
@@ -8,9 +8,24 @@ import (
 )
 
 func isConnRefused(err error) bool {
-	var sysErr *syscall.Errno
-	if errors.As(err, &sysErr) {
-		return *sysErr == syscall.ECONNREFUSED
-	}
-	return false
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isNotableErr reports whether a dial error means the host could not be
+// probed at all, as opposed to simply not answering.
+func isNotableErr(err error) bool {
+	return errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.ENETDOWN) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL) ||
+		errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.EPERM)
+}
+
+// isNetDownErr reports whether a dial error means the local network is
+// unusable (interface down or address gone), not just the target host.
+func isNetDownErr(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.ENETDOWN) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL)
 }
@@ -8,10 +8,34 @@ import (
 )
 
 func isConnRefused(err error) bool {
-	var sysErr *syscall.Errno
+	var sysErr syscall.Errno
 	if errors.As(err, &sysErr) {
 		// WSAECONNREFUSED = 10061
-		return *sysErr == 10061
+		return sysErr == 10061
+	}
+	return false
+}
+
+// isNotableErr reports whether a dial error means the host could not be
+// probed at all, as opposed to simply not answering.
+func isNotableErr(err error) bool {
+	var sysErr syscall.Errno
+	if errors.As(err, &sysErr) {
+		// WSAEACCES = 10013, WSAEADDRNOTAVAIL = 10049, WSAENETDOWN = 10050,
+		// WSAENETUNREACH = 10051, WSAEHOSTUNREACH = 10065
+		return sysErr == 10013 || sysErr == 10049 || sysErr == 10050 ||
+			sysErr == 10051 || sysErr == 10065
+	}
+	return false
+}
+
+// isNetDownErr reports whether a dial error means the local network is
+// unusable (interface down or address gone), not just the target host.
+func isNetDownErr(err error) bool {
+	var sysErr syscall.Errno
+	if errors.As(err, &sysErr) {
+		// WSAEADDRNOTAVAIL = 10049, WSAENETDOWN = 10050, WSAENETUNREACH = 10051
+		return sysErr == 10049 || sysErr == 10050 || sysErr == 10051
 	}
 	return false
 }
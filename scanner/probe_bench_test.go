@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The dial benchmarks compare the per-probe dialer used before probes
+// shared one configured dialer per scan (BenchmarkDialPerProbe) with the
+// shared dialer (BenchmarkDialShared), against a loopback port that
+// refuses the connection, as most probed ports do.
+
+func BenchmarkDialPerProbe(b *testing.B) {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(benchFreePort(b)))
+	b.ReportAllocs()
+	for b.Loop() {
+		d := net.Dialer{Timeout: time.Second}
+		if conn, err := d.Dial("tcp", addr); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func BenchmarkDialShared(b *testing.B) {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(benchFreePort(b)))
+	p := newProber(ScanOptions{Timeout: time.Second}.withDefaults())
+	b.ReportAllocs()
+	for b.Loop() {
+		if conn, err := p.dial(context.Background(), "tcp", addr, p.timeout); err == nil {
+			closeReset(conn)
+		}
+	}
+}
+
+// BenchmarkTCPProbe runs the connect probe of one host over the built-in
+// port list, with one port open on loopback and the rest refused.
+func BenchmarkTCPProbe(b *testing.B) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	ports := append([]int{ln.Addr().(*net.TCPAddr).Port}, tcpPorts...)
+	p := newProber(ScanOptions{Timeout: time.Second, TCPPorts: ports}.withDefaults())
+	b.ReportAllocs()
+	for b.Loop() {
+		p.tcpProbe(context.Background(), "127.0.0.1", false)
+	}
+}
+
+// benchFreePort returns a loopback TCP port with nothing listening on it.
+func benchFreePort(b *testing.B) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
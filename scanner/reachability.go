@@ -0,0 +1,29 @@
+package scanner
+
+import "net"
+
+// Reachability annotations for ScanResult.Reachability, comparing what
+// the host answered at layer 3 with what the ARP table shows at layer 2.
+const (
+	ReachLocal   = "local"    // answered a probe and is in the ARP table
+	ReachARPOnly = "arp-only" // in the ARP table but answered no probe: likely firewalled
+	ReachRouted  = "routed"   // answered from outside the local networks, through a router
+	ReachNoARP   = "no-arp"   // answered on a local network but never appeared in the ARP table
+)
+
+// reachability returns the L2/L3 picture of an enriched result, or "" for
+// the scanning host. Hosts heard announcing themselves count as answering.
+func reachability(r ScanResult, addrs []net.Addr) string {
+	if r.Method == "SELF" {
+		return ""
+	}
+	switch {
+	case r.Method == "ARP":
+		return ReachARPOnly
+	case !onLink(r.IP, addrs):
+		return ReachRouted
+	case r.MAC != "" && r.MAC != "-":
+		return ReachLocal
+	}
+	return ReachNoARP
+}
@@ -2163,15 +2163,29 @@ var ouiTable = map[string]string{
 	"AC:F4:73": "iRobot",
 }
 
+// DefaultDNSTimeout bounds the reverse DNS lookup of each host.
+const DefaultDNSTimeout = 1 * time.Second
+
 // ResolveHostname tries multiple methods to resolve a hostname for the given IP:
 // 1. Standard reverse DNS (PTR record)
 // 2. mDNS reverse lookup (unicast query to host:5353)
 func ResolveHostname(ip string) string {
+	return ResolveHostnameTimeout(ip, DefaultDNSTimeout)
+}
+
+// ResolveHostnameTimeout is ResolveHostname with the reverse DNS lookup
+// bounded by timeout and the mDNS fallback by half of it.
+func ResolveHostnameTimeout(ip string, timeout time.Duration) string {
+	return resolveHostname(context.Background(), ip, timeout)
+}
+
+// resolveHostname is ResolveHostnameTimeout, giving up once ctx is done.
+func resolveHostname(ctx context.Context, ip string, timeout time.Duration) string {
 	// Try standard reverse DNS with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	dnsCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	resolver := &net.Resolver{}
-	names, err := resolver.LookupAddr(ctx, ip)
+	names, err := resolver.LookupAddr(dnsCtx, ip)
 	if err == nil && len(names) > 0 {
 		hostname := strings.TrimSuffix(names[0], ".")
 		if hostname != "" {
@@ -2180,7 +2194,7 @@ func ResolveHostname(ip string) string {
 	}
 
 	// Fallback: mDNS reverse lookup
-	if name := mdnsReverseLookup(ip, 500*time.Millisecond); name != "" {
+	if name := mdnsReverseLookup(ctx, ip, timeout/2); name != "" {
 		return name
 	}
 
@@ -2188,7 +2202,7 @@ func ResolveHostname(ip string) string {
 }
 
 // mdnsReverseLookup sends a unicast mDNS PTR query to the host on port 5353.
-func mdnsReverseLookup(ip string, timeout time.Duration) string {
+func mdnsReverseLookup(ctx context.Context, ip string, timeout time.Duration) string {
 	parts := strings.Split(ip, ".")
 	if len(parts) != 4 {
 		return ""
@@ -2198,11 +2212,12 @@ func mdnsReverseLookup(ip string, timeout time.Duration) string {
 
 	query := buildPTRQuery(name)
 
-	conn, err := net.DialTimeout("udp", ip+":5353", timeout)
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "udp", ip+":5353")
 	if err != nil {
 		return ""
 	}
 	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
 
 	conn.SetDeadline(time.Now().Add(timeout))
 	if _, err := conn.Write(query); err != nil {
@@ -2220,25 +2235,7 @@ func mdnsReverseLookup(ip string, timeout time.Duration) string {
 
 // buildPTRQuery builds a DNS PTR query packet for the given name.
 func buildPTRQuery(name string) []byte {
-	var buf []byte
-	// Header
-	buf = append(buf, 0x00, 0x00) // Transaction ID
-	buf = append(buf, 0x00, 0x00) // Flags: standard query
-	buf = append(buf, 0x00, 0x01) // Questions: 1
-	buf = append(buf, 0x00, 0x00) // Answers: 0
-	buf = append(buf, 0x00, 0x00) // Authority: 0
-	buf = append(buf, 0x00, 0x00) // Additional: 0
-
-	// Question name
-	for _, label := range strings.Split(name, ".") {
-		buf = append(buf, byte(len(label)))
-		buf = append(buf, []byte(label)...)
-	}
-	buf = append(buf, 0x00)       // End of name
-	buf = append(buf, 0x00, 0x0C) // Type: PTR (12)
-	buf = append(buf, 0x00, 0x01) // Class: IN
-
-	return buf
+	return buildDNSQuery(name, dnsTypePTR)
 }
 
 // parsePTRResponse extracts the hostname from a DNS response containing a PTR record.
@@ -2420,16 +2417,35 @@ func normalizeMAC(mac string) string {
 	return strings.Join(parts, ":")
 }
 
+// RandomizedVendor is the vendor reported for randomized (locally
+// administered) MAC addresses, which have no OUI assignment.
+const RandomizedVendor = "Randomized (private)"
+
 // LookupVendor returns the vendor name for the given MAC address.
-// If the MAC is a locally administered (randomized) address, returns "Private".
+// If the MAC is a locally administered (randomized) address, returns RandomizedVendor.
 func LookupVendor(mac string) string {
+	return lookupVendor(mac, nil)
+}
+
+// lookupVendor is LookupVendor with custom prefixes (see
+// NormalizeMACPrefix) checked first, longest match winning. Custom
+// prefixes also name locally administered addresses.
+func lookupVendor(mac string, custom map[string]string) string {
 	if len(mac) < 8 {
 		return "Unknown"
 	}
+	if len(custom) > 0 {
+		mac = strings.ToUpper(mac)
+		for n := min(len(mac), 17); n >= 2; n -= 3 {
+			if vendor, ok := custom[mac[:n]]; ok {
+				return vendor
+			}
+		}
+	}
 	// Check locally administered bit (bit 1 of first octet).
 	// Devices use randomized MACs for privacy; these have no OUI assignment.
 	if isLocallyAdministered(mac) {
-		return "Private"
+		return RandomizedVendor
 	}
 	prefix := strings.ToUpper(mac[:8])
 	if vendor, ok := ouiTable[prefix]; ok {
@@ -2438,6 +2454,30 @@ func LookupVendor(mac string) string {
 	return "Unknown"
 }
 
+// NormalizeMACPrefix converts a MAC prefix of one to six octets, written
+// with colons, dashes or no separators ("dc:a6:32", "DC-A6-32",
+// "dca632"), to the upper-case colon form used by vendor lookups.
+func NormalizeMACPrefix(prefix string) (string, bool) {
+	hex := strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.TrimSpace(prefix))
+	if hex == "" || len(hex)%2 != 0 || len(hex) > 12 {
+		return "", false
+	}
+	octets := make([]string, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		if _, err := strconv.ParseUint(hex[i:i+2], 16, 8); err != nil {
+			return "", false
+		}
+		octets = append(octets, strings.ToUpper(hex[i:i+2]))
+	}
+	return strings.Join(octets, ":"), true
+}
+
+// IsRandomizedMAC reports whether mac is a randomized (private) address, as
+// used by modern phones and laptops for privacy.
+func IsRandomizedMAC(mac string) bool {
+	return len(mac) >= 8 && isLocallyAdministered(mac)
+}
+
 // isLocallyAdministered returns true if the MAC has the locally administered
 // bit set (bit 1 of the first octet), indicating a randomized/private address.
 func isLocallyAdministered(mac string) bool {
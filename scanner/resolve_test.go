@@ -0,0 +1,39 @@
+package scanner
+
+import "testing"
+
+func TestIsRandomizedMAC(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want bool
+	}{
+		// Locally administered: second-lowest bit of the first octet set
+		{"02:00:00:00:00:01", true},
+		{"12:34:56:78:9A:BC", true},
+		{"A6:83:E7:11:22:33", true},
+		{"da:a1:19:00:00:01", true},
+		{"FE:ED:FA:CE:00:01", true},
+		{"0A:00:27:00:00:00", true},
+		{"0E:00:00:00:00:00", true},
+		{"06:00:00:00:00:00", true},
+		// Globally unique
+		{"00:1B:63:84:45:E6", false},
+		{"DC:A6:32:01:02:03", false},
+		{"F0:18:98:00:00:01", false},
+		{"01:00:5E:00:00:FB", false}, // multicast, globally administered
+		{"FD:FF:FF:FF:FF:FF", false},
+		// Malformed
+		{"", false},
+		{"-", false},
+		{"02", false},
+		{"02:00:0", false},
+		{"zz:00:00:00:00:00", false},
+		{"x2:00:00:00:00:01", false},
+		{"2:00:00:00:00:00", false},
+	}
+	for _, tt := range tests {
+		if got := IsRandomizedMAC(tt.mac); got != tt.want {
+			t.Errorf("IsRandomizedMAC(%q) = %v, want %v", tt.mac, got, tt.want)
+		}
+	}
+}
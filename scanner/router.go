@@ -0,0 +1,370 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouterCredentials authenticate against the gateway's management API.
+type RouterCredentials struct {
+	Username string
+	Password string
+}
+
+// RouterLease is a DHCP client known to the router.
+type RouterLease struct {
+	IP       net.IP
+	MAC      string
+	Hostname string
+}
+
+// routerAPI is a router firmware interface that can list DHCP leases.
+type routerAPI struct {
+	name   string
+	leases func(ctx context.Context, c *http.Client, gw string, creds RouterCredentials) ([]RouterLease, error)
+}
+
+// routerAPIs are tried in order against the gateway.
+var routerAPIs = []routerAPI{
+	{"OpenWrt", openwrtLeases},
+	{"FRITZ!Box", fritzLeases},
+}
+
+// maxFritzHosts bounds the per-entry TR-064 queries on a Fritz!Box.
+const maxFritzHosts = 512
+
+// RouterLeases reads the DHCP lease table from the router at gw, trying each
+// supported firmware API in turn. It returns the leases and the name of the
+// firmware that answered.
+func RouterLeases(gw net.IP, creds RouterCredentials, timeout time.Duration) ([]RouterLease, string, error) {
+	return routerLeases(context.Background(), gw, creds, timeout)
+}
+
+// routerLeases is RouterLeases, giving up once ctx is done.
+func routerLeases(ctx context.Context, gw net.IP, creds RouterCredentials, timeout time.Duration) ([]RouterLease, string, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var errs []error
+	for _, api := range routerAPIs {
+		leases, err := api.leases(ctx, client, gw.String(), creds)
+		if err == nil {
+			return leases, api.name, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", api.name, err))
+	}
+	return nil, "", fmt.Errorf("no supported router API on %s (%w)", gw, errors.Join(errs...))
+}
+
+// fillRouterHostnames sets Hostname from the router's DHCP leases for
+// results whose hostname could not be resolved otherwise.
+func fillRouterHostnames(ctx context.Context, results []ScanResult, creds RouterCredentials, timeout time.Duration) error {
+	gw, err := DefaultGateway()
+	if err != nil {
+		return err
+	}
+	leases, _, err := routerLeases(ctx, gw, creds, timeout)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string)
+	for _, l := range leases {
+		if l.Hostname != "" && l.Hostname != "*" {
+			names[l.IP.String()] = l.Hostname
+		}
+	}
+	for i := range results {
+		if results[i].Hostname != "-" {
+			continue
+		}
+		if name, ok := names[results[i].IP.String()]; ok {
+			results[i].Hostname = name
+		}
+	}
+	return nil
+}
+
+// --- OpenWrt (ubus JSON-RPC via uhttpd) ---
+
+// ubusNullSession is the anonymous ubus session ID used to log in.
+const ubusNullSession = "00000000000000000000000000000000"
+
+func openwrtLeases(ctx context.Context, c *http.Client, gw string, creds RouterCredentials) ([]RouterLease, error) {
+	user := creds.Username
+	if user == "" {
+		user = "root"
+	}
+	var login struct {
+		Session string `json:"ubus_rpc_session"`
+	}
+	if err := ubusCall(ctx, c, gw, ubusNullSession, "session", "login",
+		map[string]string{"username": user, "password": creds.Password}, &login); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+
+	var reply struct {
+		Leases []struct {
+			Hostname string `json:"hostname"`
+			IPAddr   string `json:"ipaddr"`
+			MACAddr  string `json:"macaddr"`
+		} `json:"dhcp_leases"`
+	}
+	if err := ubusCall(ctx, c, gw, login.Session, "luci-rpc", "getDHCPLeases", struct{}{}, &reply); err != nil {
+		return nil, err
+	}
+	var leases []RouterLease
+	for _, l := range reply.Leases {
+		if ip := net.ParseIP(l.IPAddr).To4(); ip != nil {
+			leases = append(leases, RouterLease{IP: ip, MAC: normalizeMAC(l.MACAddr), Hostname: l.Hostname})
+		}
+	}
+	return leases, nil
+}
+
+// ubusCall invokes object.method over ubus JSON-RPC and decodes the result
+// data into out.
+func ubusCall(ctx context.Context, c *http.Client, gw, session, object, method string, args, out any) error {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "call",
+		"params":  []any{session, object, method, args},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+gw+"/ubus", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var rpc struct {
+		Result []json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&rpc); err != nil {
+		return fmt.Errorf("decode reply: %w", err)
+	}
+	if rpc.Error != nil {
+		return errors.New(rpc.Error.Message)
+	}
+	if len(rpc.Result) == 0 {
+		return fmt.Errorf("empty reply")
+	}
+	var status int
+	if err := json.Unmarshal(rpc.Result[0], &status); err != nil || status != 0 {
+		return fmt.Errorf("ubus status %d", status)
+	}
+	if len(rpc.Result) < 2 {
+		return fmt.Errorf("reply has no data")
+	}
+	return json.Unmarshal(rpc.Result[1], out)
+}
+
+// --- FRITZ!Box (TR-064 SOAP on port 49000) ---
+
+const (
+	fritzHostsURL     = "/upnp/control/hosts"
+	fritzHostsService = "urn:dslforum-org:service:Hosts:1"
+)
+
+func fritzLeases(ctx context.Context, c *http.Client, gw string, creds RouterCredentials) ([]RouterLease, error) {
+	base := "http://" + net.JoinHostPort(gw, "49000")
+	reply, err := fritzCall(ctx, c, base, creds, "GetHostNumberOfEntries", nil)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(reply["NewHostNumberOfEntries"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid host count %q", reply["NewHostNumberOfEntries"])
+	}
+
+	var leases []RouterLease
+	for i := 0; i < min(n, maxFritzHosts); i++ {
+		entry, err := fritzCall(ctx, c, base, creds, "GetGenericHostEntry", map[string]string{"NewIndex": strconv.Itoa(i)})
+		if err != nil {
+			return leases, err
+		}
+		if ip := net.ParseIP(entry["NewIPAddress"]).To4(); ip != nil {
+			leases = append(leases, RouterLease{
+				IP:       ip,
+				MAC:      normalizeMAC(entry["NewMACAddress"]),
+				Hostname: entry["NewHostName"],
+			})
+		}
+	}
+	return leases, nil
+}
+
+// fritzCall invokes a TR-064 Hosts action and returns the New* output
+// arguments. Digest authentication is answered when the box asks for it.
+func fritzCall(ctx context.Context, c *http.Client, base string, creds RouterCredentials, action string, args map[string]string) (map[string]string, error) {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	sb.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&sb, `<u:%s xmlns:u="%s">`, action, fritzHostsService)
+	for k, v := range args {
+		fmt.Fprintf(&sb, "<%s>", k)
+		xml.EscapeText(&sb, []byte(v))
+		fmt.Fprintf(&sb, "</%s>", k)
+	}
+	fmt.Fprintf(&sb, `</u:%s></s:Body></s:Envelope>`, action)
+	body := sb.String()
+
+	newReq := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+fritzHostsURL, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+		req.Header.Set("SOAPAction", fritzHostsService+"#"+action)
+		return req, nil
+	}
+
+	req, err := newReq()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if req, err = newReq(); err != nil {
+			return nil, err
+		}
+		auth, err := digestAuth(challenge, creds, req.Method, fritzHostsURL)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+		if resp, err = c.Do(req); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", action, resp.StatusCode)
+	}
+	return parseSOAPArgs(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseSOAPArgs collects the text of New* elements in a SOAP response.
+func parseSOAPArgs(r io.Reader) (map[string]string, error) {
+	out := make(map[string]string)
+	dec := xml.NewDecoder(r)
+	var current string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode reply: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = ""
+			if strings.HasPrefix(t.Name.Local, "New") {
+				current = t.Name.Local
+			}
+		case xml.CharData:
+			if current != "" {
+				out[current] += string(t)
+			}
+		case xml.EndElement:
+			current = ""
+		}
+	}
+}
+
+// digestAuth answers an HTTP Digest challenge (RFC 2617, MD5, qop=auth).
+func digestAuth(challenge string, creds RouterCredentials, method, uri string) (string, error) {
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return "", fmt.Errorf("unsupported authentication %q", scheme)
+	}
+	if creds.Password == "" {
+		return "", fmt.Errorf("router requires a password")
+	}
+	params := parseAuthParams(rest)
+
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := md5hex(creds.Username + ":" + params["realm"] + ":" + creds.Password)
+	ha2 := md5hex(method + ":" + uri)
+
+	h := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`,
+		creds.Username, params["realm"], params["nonce"], uri)
+	if qop := params["qop"]; qop != "" {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		cnonce := hex.EncodeToString(buf)
+		resp := md5hex(ha1 + ":" + params["nonce"] + ":00000001:" + cnonce + ":auth:" + ha2)
+		h += fmt.Sprintf(`, qop=auth, nc=00000001, cnonce="%s", response="%s"`, cnonce, resp)
+	} else {
+		h += fmt.Sprintf(`, response="%s"`, md5hex(ha1+":"+params["nonce"]+":"+ha2))
+	}
+	if opaque := params["opaque"]; opaque != "" {
+		h += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return h, nil
+}
+
+// parseAuthParams parses comma-separated key=value pairs of an
+// authentication challenge, where values may be quoted and contain commas.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, s = rest[1:], ""
+			} else {
+				value, s = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
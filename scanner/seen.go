@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SeenRecord tracks when a device was first and last seen across scans.
+type SeenRecord struct {
+	IP        string    `json:"ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SeenStore maps a device key (its MAC, or its IP when the MAC is unknown)
+// to when it was seen. Unlike the diff history it keeps devices that have
+// since disappeared.
+type SeenStore map[string]SeenRecord
+
+func seenPath() string {
+	return filepath.Join(DataDir(), "seen.json")
+}
+
+// seenKey identifies a device across scans, preferring the MAC so that a
+// DHCP address change does not reset its first-seen time.
+func seenKey(r ScanResult) string {
+	if r.MAC != "" && r.MAC != "-" {
+		return r.MAC
+	}
+	return r.IP.String()
+}
+
+// LoadSeen reads the seen store from ~/.localscan/seen.json. A missing file
+// yields an empty store.
+func LoadSeen() (SeenStore, error) {
+	store := make(SeenStore)
+	data, err := os.ReadFile(seenPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Save writes the seen store to ~/.localscan/seen.json.
+func (s SeenStore) Save() error {
+	p := seenPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, false)
+}
+
+// JoinSeen records the hosts in results as seen at now and fills in each
+// result's FirstSeen and LastSeen from store. GONE results only read the
+// store, so their LastSeen is the last scan that found them.
+func JoinSeen(results []ScanResult, store SeenStore, now time.Time) {
+	for i := range results {
+		r := &results[i]
+		key := seenKey(*r)
+		rec, ok := store[key]
+		if r.Status != "GONE" {
+			if !ok {
+				rec.FirstSeen = now
+			}
+			rec.IP = r.IP.String()
+			rec.LastSeen = now
+			store[key] = rec
+		}
+		r.FirstSeen, r.LastSeen = rec.FirstSeen, rec.LastSeen
+	}
+}
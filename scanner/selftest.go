@@ -0,0 +1,179 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// selfTestReply prefixes the mock UDP service's replies.
+var selfTestReply = []byte("LSOK")
+
+// mockHost is a loopback address with the services the self-test runs on it.
+type mockHost struct {
+	ip   string
+	tcp  int  // number of TCP ports listened on (the first n of the test ports)
+	udp  bool // answers the custom UDP probe
+	want map[string]mockWant
+}
+
+// mockWant is the expected outcome for a host under one method order.
+type mockWant struct {
+	method string // "" if the host must not be found
+	ports  int    // number of open TCP ports
+}
+
+// selfTestCases are the method orders the self-test scans with.
+var selfTestCases = [][]string{
+	{MethodTCP},
+	{MethodUDP},
+	{MethodUDP, MethodTCP},
+}
+
+// SelfTest scans mock TCP/UDP listeners on loopback aliases (127.0.0.2-5)
+// with several method orders, writes the detection accuracy and timing of
+// each to w, and reports whether every host was classified correctly.
+// Loopback answers TCP with a reset on closed ports, so every address is
+// alive to TCP; the cases check methods and open ports rather than absence.
+func SelfTest(w io.Writer) (bool, error) {
+	hosts := []*mockHost{
+		{ip: "127.0.0.2", tcp: 1, want: map[string]mockWant{"tcp": {"TCP", 1}, "udp": {}, "udp,tcp": {"TCP", 1}}},
+		{ip: "127.0.0.3", tcp: 2, want: map[string]mockWant{"tcp": {"TCP", 2}, "udp": {}, "udp,tcp": {"TCP", 2}}},
+		{ip: "127.0.0.4", udp: true, want: map[string]mockWant{"tcp": {"TCP", 0}, "udp": {"UDP", 0}, "udp,tcp": {"UDP", 0}}},
+		{ip: "127.0.0.5", want: map[string]mockWant{"tcp": {"TCP", 0}, "udp": {}, "udp,tcp": {"TCP", 0}}},
+	}
+
+	ports, udpPort, closeAll, err := startMocks(hosts)
+	if err != nil {
+		return false, fmt.Errorf("start mock hosts (loopback aliases 127.0.0.2-5 are required): %w", err)
+	}
+	defer closeAll()
+
+	fmt.Fprintf(w, "Self-test: %d mock hosts on %s-%s (TCP ports %d, %d; UDP port %d)\n",
+		len(hosts), hosts[0].ip, hosts[len(hosts)-1].ip, ports[0], ports[1], udpPort)
+
+	ips := make([]net.IP, len(hosts))
+	for i, h := range hosts {
+		ips[i] = net.ParseIP(h.ip).To4()
+	}
+
+	correct, total := 0, 0
+	for _, order := range selfTestCases {
+		name := strings.Join(order, ",")
+		opts := ScanOptions{
+			Timeout:     200 * time.Millisecond,
+			MethodOrder: order,
+			TCPPorts:    ports,
+			UDPPorts:    []int{udpPort},
+			UDPProbes:   []UDPProbe{{Port: udpPort, Payload: []byte("localscan-self-test"), Match: selfTestReply}},
+		}
+		start := time.Now()
+		results, _ := ScanGroups([][]net.IP{ips}, opts, nil)
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		var failures []string
+		for _, h := range hosts {
+			want := h.want[name]
+			got, gotPorts := "", 0
+			for _, r := range results {
+				if r.IP.String() == h.ip {
+					got, gotPorts = r.Method, len(r.OpenPorts)
+				}
+			}
+			if got == want.method && gotPorts == want.ports {
+				correct++
+			} else {
+				failures = append(failures, fmt.Sprintf("%s: want %s with %d open, got %s with %d open",
+					h.ip, orNone(want.method), want.ports, orNone(got), gotPorts))
+			}
+			total++
+		}
+		fmt.Fprintf(w, "  %-8s  %d/%d correct  %s\n", name, len(hosts)-len(failures), len(hosts), elapsed)
+		for _, f := range failures {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+	}
+
+	pass := correct == total
+	verdict := "PASS"
+	if !pass {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(w, "Accuracy: %d/%d (%.1f%%) %s\n", correct, total, 100*float64(correct)/float64(total), verdict)
+	return pass, nil
+}
+
+// startMocks starts the listeners for hosts. All hosts share the two TCP
+// test ports and the UDP port, which are picked by the first listeners.
+func startMocks(hosts []*mockHost) ([]int, int, func(), error) {
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	ports := []int{0, 0}
+	udpPort := 0
+
+	for _, h := range hosts {
+		for i := 0; i < h.tcp; i++ {
+			ln, err := net.Listen("tcp4", net.JoinHostPort(h.ip, strconv.Itoa(ports[i])))
+			if err != nil {
+				closeAll()
+				return nil, 0, nil, err
+			}
+			closers = append(closers, ln)
+			ports[i] = ln.Addr().(*net.TCPAddr).Port
+			go acceptAndClose(ln)
+		}
+		if h.udp {
+			conn, err := net.ListenPacket("udp4", net.JoinHostPort(h.ip, strconv.Itoa(udpPort)))
+			if err != nil {
+				closeAll()
+				return nil, 0, nil, err
+			}
+			closers = append(closers, conn)
+			udpPort = conn.LocalAddr().(*net.UDPAddr).Port
+			go echoUDP(conn)
+		}
+	}
+	if ports[0] == 0 || ports[1] == 0 || udpPort == 0 {
+		closeAll()
+		return nil, 0, nil, fmt.Errorf("mock hosts do not cover two TCP ports and a UDP port")
+	}
+	return ports, udpPort, closeAll, nil
+}
+
+// acceptAndClose accepts connections until ln is closed.
+func acceptAndClose(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}
+
+// echoUDP answers every packet with selfTestReply followed by the packet.
+func echoUDP(conn net.PacketConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(append(slices.Clone(selfTestReply), buf[:n]...), addr)
+	}
+}
+
+func orNone(method string) string {
+	if method == "" {
+		return "not found"
+	}
+	return method
+}
@@ -0,0 +1,51 @@
+package scanner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSelfTestPass(t *testing.T) {
+	var buf bytes.Buffer
+	pass, err := SelfTest(&buf)
+	if err != nil {
+		t.Skipf("loopback aliases unavailable: %v", err)
+	}
+	out := buf.String()
+	if !pass || !strings.Contains(out, "Accuracy: 12/12 (100.0%) PASS") {
+		t.Errorf("SelfTest = %v, output:\n%s", pass, out)
+	}
+	for _, order := range []string{"tcp", "udp", "udp,tcp"} {
+		if !strings.Contains(out, "  "+order+" ") {
+			t.Errorf("output lacks a line for %s:\n%s", order, out)
+		}
+	}
+}
+
+// TestSelfTestFail adds a method order the mock hosts have no expectations
+// for, so every host the scan finds under it counts as misclassified.
+func TestSelfTestFail(t *testing.T) {
+	saved := selfTestCases
+	selfTestCases = [][]string{{MethodTCP}, {MethodTCP, MethodUDP}}
+	t.Cleanup(func() { selfTestCases = saved })
+
+	var buf bytes.Buffer
+	pass, err := SelfTest(&buf)
+	if err != nil {
+		t.Skipf("loopback aliases unavailable: %v", err)
+	}
+	out := buf.String()
+	if pass {
+		t.Fatalf("SelfTest passed with unexpected results:\n%s", out)
+	}
+	for _, want := range []string{
+		"tcp,udp   0/4 correct",
+		"127.0.0.2: want not found with 0 open, got TCP with 1 open",
+		"Accuracy: 4/8 (50.0%) FAIL",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SNMP versions accepted by ParseSNMPVersion.
+const (
+	SNMPv1  = "1"
+	SNMPv2c = "2c"
+)
+
+// DefaultSNMPCommunity is the community string probed when none is set.
+const DefaultSNMPCommunity = "public"
+
+// sysDescrOID is 1.3.6.1.2.1.1.1.0 (SNMPv2-MIB::sysDescr.0), BER-encoded.
+var sysDescrOID = []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+
+// SNMPInfo records an SNMP agent that answered a community string. A
+// guessable community such as "public" or "private" is a finding in
+// itself: anyone on the network can read (or write) the device's settings.
+type SNMPInfo struct {
+	Version   string `json:"version"`
+	Community string `json:"community"`
+	SysDescr  string `json:"sys_descr,omitempty"`
+}
+
+// ParseSNMPVersion checks an SNMP version name: "1" or "2c" (also "v1",
+// "v2c"). An empty string means SNMPv1.
+func ParseSNMPVersion(s string) (string, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v") {
+	case "", SNMPv1:
+		return SNMPv1, nil
+	case SNMPv2c:
+		return SNMPv2c, nil
+	}
+	return "", fmt.Errorf("unknown SNMP version %q (use 1 or 2c)", s)
+}
+
+// snmpGet builds a GetRequest for sysDescr.0 with request-id 1, which
+// isSNMPResponse looks for in the reply.
+func snmpGet(version, community string) []byte {
+	ver := byte(0x00)
+	if version == SNMPv2c {
+		ver = 0x01
+	}
+	varbind := berTLV(0x30, berTLV(0x06, sysDescrOID), []byte{0x05, 0x00})
+	pdu := berTLV(0xa0,
+		[]byte{0x02, 0x04, 0x00, 0x00, 0x00, 0x01}, // request-id
+		[]byte{0x02, 0x01, 0x00},                   // error-status
+		[]byte{0x02, 0x01, 0x00},                   // error-index
+		berTLV(0x30, varbind))
+	return berTLV(0x30, []byte{0x02, 0x01, ver}, berTLV(0x04, []byte(community)), pdu)
+}
+
+// berTLV encodes a BER tag-length-value with the concatenated contents.
+func berTLV(tag byte, contents ...[]byte) []byte {
+	var body []byte
+	for _, c := range contents {
+		body = append(body, c...)
+	}
+	out := []byte{tag}
+	switch n := len(body); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, body...)
+}
+
+// berNext splits the first TLV off b, returning its tag and contents and
+// the bytes after it.
+func berNext(b []byte) (tag byte, contents, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return 0, nil, nil, false
+	}
+	tag, n, hdr := b[0], int(b[1]), 2
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 2 || len(b) < 2+octets {
+			return 0, nil, nil, false
+		}
+		n = 0
+		for _, c := range b[2 : 2+octets] {
+			n = n<<8 | int(c)
+		}
+		hdr += octets
+	}
+	if len(b) < hdr+n {
+		return 0, nil, nil, false
+	}
+	return tag, b[hdr : hdr+n], b[hdr+n:], true
+}
+
+// snmpDescr returns the sysDescr string of a GetResponse, or ok false if
+// reply is not a successful response carrying an OCTET STRING value.
+func snmpDescr(reply []byte) (string, bool) {
+	_, msg, _, ok := berNext(reply) // message SEQUENCE
+	if !ok {
+		return "", false
+	}
+	var tag byte
+	var pdu []byte
+	for range 2 { // skip version and community
+		if _, _, msg, ok = berNext(msg); !ok {
+			return "", false
+		}
+	}
+	if tag, pdu, _, ok = berNext(msg); !ok || tag != 0xa2 {
+		return "", false
+	}
+	var status []byte
+	if _, _, pdu, ok = berNext(pdu); !ok { // request-id
+		return "", false
+	}
+	if _, status, pdu, ok = berNext(pdu); !ok || len(status) != 1 || status[0] != 0 {
+		return "", false
+	}
+	if _, _, pdu, ok = berNext(pdu); !ok { // error-index
+		return "", false
+	}
+	_, list, _, ok := berNext(pdu)
+	if !ok {
+		return "", false
+	}
+	_, varbind, _, ok := berNext(list)
+	if !ok {
+		return "", false
+	}
+	_, _, value, ok := berNext(varbind) // skip the OID
+	if !ok {
+		return "", false
+	}
+	tag, descr, _, ok := berNext(value)
+	if !ok || tag != 0x04 {
+		return "", false
+	}
+	return cleanBanner(string(descr)), true
+}
+
+// ProbeSNMP tries each community on ip's SNMP port in turn and returns the
+// first that the agent answers, with its sysDescr, or nil if none does.
+// Agents drop requests with a wrong community silently, so each miss costs
+// a full timeout. Replies are read into readLimit bytes (DefaultReadLimit
+// if 0).
+func ProbeSNMP(ip string, communities []string, version string, timeout time.Duration, readLimit int) *SNMPInfo {
+	return probeSNMP(context.Background(), ip, communities, version, timeout, readLimit, nil)
+}
+
+// probeSNMP is ProbeSNMP, sending from source if it is not nil and giving
+// up once ctx is done.
+func probeSNMP(ctx context.Context, ip string, communities []string, version string, timeout time.Duration, readLimit int, source net.IP) *SNMPInfo {
+	p := newProber(ScanOptions{Timeout: timeout, ReadLimit: readLimit, SourceIP: source}.withDefaults())
+	addr := net.JoinHostPort(ip, strconv.Itoa(161))
+	bufp := p.readBufs.Get().(*[]byte)
+	defer p.readBufs.Put(bufp)
+	buf := *bufp
+	for _, community := range communities {
+		conn, err := p.dial(ctx, "udp", addr, timeout)
+		if err != nil {
+			return nil
+		}
+		stop := closeOnDone(ctx, conn)
+		conn.SetDeadline(time.Now().Add(timeout))
+		_, err = conn.Write(snmpGet(version, community))
+		n := 0
+		if err == nil {
+			n, err = conn.Read(buf)
+		}
+		stop()
+		conn.Close()
+		if err != nil {
+			continue
+		}
+		if descr, ok := snmpDescr(buf[:n]); ok {
+			return &SNMPInfo{Version: version, Community: community, SysDescr: descr}
+		}
+	}
+	return nil
+}
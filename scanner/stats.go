@@ -0,0 +1,91 @@
+package scanner
+
+import "sync/atomic"
+
+// Approximate on-the-wire sizes of probe packets (IPv4 headers included,
+// link-layer framing not), used to estimate a scan's footprint.
+const (
+	synBytes     = 60 // SYN from the OS stack, with the usual TCP options
+	rawSYNBytes  = 40 // SYN built for SYN mode, without options
+	ackBytes     = 40 // bare ACK, RST or FIN segment
+	udpHeader    = 28 // IPv4 + UDP headers
+	icmpEchoSize = 84 // default ping: 56 data bytes + ICMP and IPv4 headers
+)
+
+// ProbeStats estimates the probe traffic a scan sent. Packets and Bytes
+// count what the scanner sent, including the handshake completion and
+// reset of TCP connects; replies and the ARP requests the OS sends on the
+// scanner's behalf are not included.
+type ProbeStats struct {
+	TCPConnects int64 `json:"tcp_connects"` // TCP connection attempts (SYNs in SYN mode)
+	UDPPackets  int64 `json:"udp_packets"`
+	ICMPEchoes  int64 `json:"icmp_echoes"`
+	Packets     int64 `json:"packets"`
+	Bytes       int64 `json:"bytes"`
+}
+
+// probeCounters accumulates ProbeStats across workers.
+type probeCounters struct {
+	tcp, udp, icmp, packets, bytes atomic.Int64
+}
+
+func (c *probeCounters) sent(packets, bytes int64) {
+	c.packets.Add(packets)
+	c.bytes.Add(bytes)
+}
+
+// tcpDial counts a connect attempt; an accepted connection also sends the
+// final ACK and, when closed, a reset.
+func (c *probeCounters) tcpDial(accepted bool) {
+	c.tcp.Add(1)
+	if accepted {
+		c.sent(3, synBytes+2*ackBytes)
+	} else {
+		c.sent(1, synBytes)
+	}
+}
+
+// tcpData counts a payload sent on an open connection.
+func (c *probeCounters) tcpData(n int) {
+	c.sent(1, int64(ackBytes+n))
+}
+
+// rawSYNs counts the SYNs sent for one host in SYN mode.
+func (c *probeCounters) rawSYNs(n int) {
+	c.tcp.Add(int64(n))
+	c.sent(int64(n), int64(n*rawSYNBytes))
+}
+
+// udpPacket counts a UDP probe with an n-byte payload.
+func (c *probeCounters) udpPacket(n int) {
+	c.udp.Add(1)
+	c.sent(1, int64(udpHeader+n))
+}
+
+// icmpEcho counts an echo request.
+func (c *probeCounters) icmpEcho() {
+	c.icmp.Add(1)
+	c.sent(1, icmpEchoSize)
+}
+
+// stats returns a snapshot of the counters.
+func (c *probeCounters) stats() ProbeStats {
+	return ProbeStats{
+		TCPConnects: c.tcp.Load(),
+		UDPPackets:  c.udp.Load(),
+		ICMPEchoes:  c.icmp.Load(),
+		Packets:     c.packets.Load(),
+		Bytes:       c.bytes.Load(),
+	}
+}
+
+// plus returns the sum of s and o.
+func (s ProbeStats) plus(o ProbeStats) ProbeStats {
+	return ProbeStats{
+		TCPConnects: s.TCPConnects + o.TCPConnects,
+		UDPPackets:  s.UDPPackets + o.UDPPackets,
+		ICMPEchoes:  s.ICMPEchoes + o.ICMPEchoes,
+		Packets:     s.Packets + o.Packets,
+		Bytes:       s.Bytes + o.Bytes,
+	}
+}
@@ -0,0 +1,175 @@
+//go:build linux
+
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// synAvailable reports whether raw TCP sockets can be opened, which
+// requires root or CAP_NET_RAW.
+func synAvailable() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return fmt.Errorf("raw socket: %w (SYN scan requires root or CAP_NET_RAW)", err)
+	}
+	syscall.Close(fd)
+	return nil
+}
+
+// synProbe sends a SYN to each port and classifies the replies without
+// completing the handshake: SYN-ACK means open, RST means closed (but the
+// host is alive), and no reply means filtered. The SYNs are sent from src,
+// or from the address the OS would pick if src is nil.
+func synProbe(ip string, ports []int, timeout time.Duration, src net.IP) (bool, portStates, error) {
+	dst := net.ParseIP(ip).To4()
+	if dst == nil {
+		return false, portStates{}, fmt.Errorf("not an IPv4 address: %s", ip)
+	}
+	if src == nil {
+		var err error
+		if src, err = sourceIPFor(dst); err != nil {
+			return false, portStates{}, err
+		}
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		return false, portStates{}, err
+	}
+	defer syscall.Close(fd)
+
+	local := &syscall.SockaddrInet4{}
+	copy(local.Addr[:], src.To4())
+	if err := syscall.Bind(fd, local); err != nil {
+		return false, portStates{}, err
+	}
+
+	// Short receive timeout so the read loop can check the overall deadline.
+	tv := syscall.NsecToTimeval((50 * time.Millisecond).Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return false, portStates{}, err
+	}
+
+	srcPort := uint16(32768 + rand.Intn(28000))
+	seq := rand.Uint32()
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], dst)
+	for _, port := range ports {
+		pkt := buildSYN(src, dst, srcPort, uint16(port), seq)
+		if err := syscall.Sendto(fd, pkt, 0, sa); err != nil {
+			return false, portStates{}, err
+		}
+	}
+
+	alive := false
+	var states portStates
+	pending := make(map[int]bool, len(ports))
+	for _, p := range ports {
+		pending[p] = true
+	}
+
+	buf := make([]byte, 1500)
+	deadline := time.Now().Add(timeout)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			continue // EAGAIN on receive timeout
+		}
+		port, flags, ok := parseSYNReply(buf[:n], dst, srcPort, seq)
+		if !ok || !pending[port] {
+			continue
+		}
+		switch {
+		case flags&tcpSYN != 0 && flags&tcpACK != 0:
+			alive = true
+			states.open = append(states.open, port)
+			delete(pending, port)
+		case flags&tcpRST != 0:
+			alive = true
+			states.closed = append(states.closed, port)
+			delete(pending, port)
+		}
+	}
+	return alive, states, nil
+}
+
+const (
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpACK = 0x10
+)
+
+// buildSYN builds a 20-byte TCP header with only the SYN flag set.
+func buildSYN(src, dst net.IP, srcPort, dstPort uint16, seq uint32) []byte {
+	h := make([]byte, 20)
+	binary.BigEndian.PutUint16(h[0:], srcPort)
+	binary.BigEndian.PutUint16(h[2:], dstPort)
+	binary.BigEndian.PutUint32(h[4:], seq)
+	h[12] = 5 << 4 // data offset: 5 words
+	h[13] = tcpSYN
+	binary.BigEndian.PutUint16(h[14:], 1024) // window
+	binary.BigEndian.PutUint16(h[16:], tcpChecksum(src, dst, h))
+	return h
+}
+
+// tcpChecksum computes the TCP checksum over the IPv4 pseudo-header and segment.
+func tcpChecksum(src, dst net.IP, segment []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+	add(src.To4())
+	add(dst.To4())
+	sum += syscall.IPPROTO_TCP
+	sum += uint32(len(segment))
+	add(segment)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// parseSYNReply extracts the remote port and TCP flags from a raw IPv4
+// packet if it is a reply to one of our SYNs.
+func parseSYNReply(pkt []byte, dst net.IP, srcPort uint16, seq uint32) (int, byte, bool) {
+	if len(pkt) < 20 {
+		return 0, 0, false
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if ihl < 20 || len(pkt) < ihl+20 {
+		return 0, 0, false
+	}
+	if !net.IP(pkt[12:16]).Equal(dst) {
+		return 0, 0, false
+	}
+	tcp := pkt[ihl:]
+	if binary.BigEndian.Uint16(tcp[2:]) != srcPort {
+		return 0, 0, false
+	}
+	flags := tcp[13]
+	if flags&tcpACK != 0 && binary.BigEndian.Uint32(tcp[8:]) != seq+1 {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(tcp[0:])), flags, true
+}
+
+// sourceIPFor returns the local address the OS would use to reach dst.
+func sourceIPFor(dst net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.To4(), nil
+}
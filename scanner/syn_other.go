@@ -0,0 +1,17 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+func synAvailable() error {
+	return errors.New("SYN scan is only supported on Linux")
+}
+
+func synProbe(ip string, ports []int, timeout time.Duration, src net.IP) (bool, portStates, error) {
+	return false, portStates{}, synAvailable()
+}
@@ -0,0 +1,197 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Target is a host to scan together with the name it was resolved from.
+type Target struct {
+	IP    net.IP
+	Name  string // hostname the IP came from ("" for literal IPs and CIDRs)
+	Ports []int  // the only TCP ports to check, from "host:port" specs
+}
+
+// ResolveTargets expands target specs into a deduplicated host list.
+// Each spec may be an IPv4 address, a CIDR, a start-end address range, or
+// a hostname; a hostname
+// that resolves to several A records contributes every address, each tagged
+// with the hostname. An address or hostname may carry a port, as in
+// "192.168.1.5:8080", to check only that TCP port on it; a host given
+// with several ports is listed once with all of them. The first
+// occurrence of an IP wins.
+func ResolveTargets(specs []string) ([]Target, error) {
+	sources, names, ports, err := resolveTargetSources(specs)
+	if err != nil {
+		return nil, err
+	}
+	var targets []Target
+	for i, s := range sources {
+		for ip := range s.hosts() {
+			if !anyContains(sources[:i], ip) {
+				targets = append(targets, Target{IP: ip, Name: names[ip.String()], Ports: ports[ip.String()]})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// resolveTargetSources turns target specs into lazy host sources: one
+// network source per CIDR, one range source per address range, and one
+// list source per run of literal IPs and
+// hostnames. names maps each hostname-derived IP to its hostname, and
+// ports each IP given as "host:port" to its sorted ports. Like the hosts
+// themselves, this metadata comes from an IP's first occurrence only: an
+// IP already in an earlier CIDR or range gets no name or ports, and the
+// ports of later "host:port" specs are only merged if the first one had
+// a port too.
+func resolveTargetSources(specs []string) ([]hostSource, map[string]string, map[string][]int, error) {
+	var (
+		sources []hostSource
+		list    []net.IP
+		seen    = make(map[string]bool)
+		names   = make(map[string]string)
+		ports   = make(map[string][]int)
+	)
+	flush := func() {
+		if len(list) > 0 {
+			sources = append(sources, newListSource(list))
+			list = nil
+		}
+	}
+	add := func(ip net.IP, name string, port int) {
+		key := ip.String()
+		if seen[key] {
+			if have, ok := ports[key]; ok && port != 0 {
+				if i, found := slices.BinarySearch(have, port); !found {
+					ports[key] = slices.Insert(have, i, port)
+				}
+			}
+			return
+		}
+		seen[key] = true
+		if anyContains(sources, ip) {
+			return // scanned, without metadata, as part of an earlier CIDR or range
+		}
+		if name != "" {
+			names[key] = name
+		}
+		if port != 0 {
+			ports[key] = []int{port}
+		}
+		list = append(list, ip)
+	}
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		if strings.Contains(spec, "/") {
+			_, network, err := net.ParseCIDR(spec)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("invalid CIDR %q: %w", spec, err)
+			}
+			if network.IP.To4() == nil {
+				return nil, nil, nil, fmt.Errorf("invalid CIDR %q: only IPv4 is supported", spec)
+			}
+			if ones, _ := network.Mask.Size(); ones == 0 {
+				return nil, nil, nil, fmt.Errorf("invalid CIDR %q: a /0 mask covers the whole IPv4 space; use a smaller range", spec)
+			}
+			flush()
+			sources = append(sources, networkSource{network: network})
+			continue
+		}
+
+		if r, ok, err := parseRange(spec); ok {
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			flush()
+			sources = append(sources, r)
+			continue
+		}
+
+		host, port, err := splitTargetPort(spec)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ips, name, err := lookupTarget(host)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, ip := range ips {
+			add(ip, name, port)
+		}
+	}
+	flush()
+	return sources, names, ports, nil
+}
+
+// splitTargetPort splits a "host:port" target spec, returning a zero port
+// for a spec without one.
+func splitTargetPort(spec string) (string, int, error) {
+	host, p, err := net.SplitHostPort(spec)
+	if err != nil {
+		return spec, 0, nil
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid target %q: bad port %q", spec, p)
+	}
+	return host, port, nil
+}
+
+// lookupTarget returns the IPv4 address of a literal IP, or the IPv4
+// addresses a hostname resolves to along with the hostname.
+func lookupTarget(spec string) ([]net.IP, string, error) {
+	if ip := net.ParseIP(spec); ip != nil {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, "", fmt.Errorf("invalid target %q: only IPv4 is supported", spec)
+		}
+		return []net.IP{ip4}, "", nil
+	}
+
+	ips, err := net.LookupIP(spec)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve %q: %w", spec, err)
+	}
+	var found []net.IP
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			found = append(found, ip4)
+		}
+	}
+	if len(found) == 0 {
+		return nil, "", fmt.Errorf("resolve %q: no IPv4 addresses", spec)
+	}
+	return found, spec, nil
+}
+
+// ReadTargetsFile reads target specs from a file, one per line.
+// Blank lines and lines starting with # are ignored.
+func ReadTargetsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var specs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+	return specs, sc.Err()
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveTargetsOverlap(t *testing.T) {
+	targets, err := ResolveTargets([]string{
+		"127.0.0.0/30",  // 127.0.0.1-2
+		"127.0.0.1",     // already in the CIDR
+		"localhost",     // 127.0.0.1 again: no name, the CIDR came first
+		"127.0.0.2:80",  // in the CIDR: no port check either
+		"127.0.0.9:22",  // first occurrence, with a port
+		"127.0.0.9:443", // merged into the first occurrence's ports
+		"127.0.0.8/30",  // 127.0.0.9-10; .9 was listed first
+		"127.0.0.10",    // in the CIDR before it
+		"127.0.0.1-127.0.0.3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, tg := range targets {
+		got = append(got, tg.IP.String())
+	}
+	want := []string{"127.0.0.1", "127.0.0.2", "127.0.0.9", "127.0.0.10", "127.0.0.3"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("hosts = %v, want %v", got, want)
+	}
+	for _, tg := range targets {
+		switch tg.IP.String() {
+		case "127.0.0.9":
+			if !slices.Equal(tg.Ports, []int{22, 443}) {
+				t.Errorf("127.0.0.9 ports = %v, want [22 443]", tg.Ports)
+			}
+		default:
+			if tg.Name != "" || tg.Ports != nil {
+				t.Errorf("%s got metadata from a later occurrence: name %q, ports %v", tg.IP, tg.Name, tg.Ports)
+			}
+		}
+	}
+}
+
+func TestResolveTargetsFirstNameWins(t *testing.T) {
+	targets, err := ResolveTargets([]string{"localhost", "127.0.0.1", "127.0.0.0/30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("got %d hosts, want 2", len(targets))
+	}
+	if targets[0].IP.String() != "127.0.0.1" || targets[0].Name != "localhost" {
+		t.Errorf("first host = %s %q, want 127.0.0.1 named localhost", targets[0].IP, targets[0].Name)
+	}
+}
+
+func TestPlanCountsOverlapOnce(t *testing.T) {
+	plan, err := NewPlan(ScanOptions{Targets: []string{"127.0.0.0/29", "127.0.0.3", "127.0.0.1-127.0.0.9"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := plan.Count(); n != 9 {
+		t.Errorf("Count = %d, want 9 (127.0.0.1-9 once each)", n)
+	}
+	if n := len(plan.Hosts()); n != 9 {
+		t.Errorf("len(Hosts) = %d, want 9", n)
+	}
+}
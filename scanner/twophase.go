@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// ScanPhase is the outcome of one pass of a two-phase scan.
+type ScanPhase struct {
+	Name    string        // "discovery" or "ports"
+	Hosts   int           // hosts probed
+	Found   int           // hosts that answered
+	Elapsed time.Duration // time the pass took, including its ARP phase
+}
+
+// twoPhase is scanSources for ScanOptions.TwoPhase. A quiet pass pings
+// every host and reads the ARP table; only the hosts it finds get the TCP
+// and UDP probes of the second pass, which fills in their ports. Hosts
+// keep the method that found them in the first pass. The found hosts are
+// sent on progressCh during the first pass, before their ports are known;
+// the second pass sends no progress. Probe errors of both passes are
+// returned: those of hosts the first pass did not find, and those of found
+// hosts that answered none of the port pass's probes. Found hosts whose port pass did not run, because ctx was done
+// first, count as not scanned, so that the report shows the scan as
+// interrupted. ScanOptions.MaxHosts only limits the first pass; the hosts
+// it found still get their ports probed.
+func (p *Plan) twoPhase(ctx context.Context, opts ScanOptions, echoed map[string]bool, progressCh chan<- Progress) ([]ScanResult, []HostError, int, ProbeStats, []ScanPhase, error) {
+	quiet, loud := opts, opts
+	quiet.MethodOrder, loud.MethodOrder = splitQuietMethods(newProber(opts).order)
+	quiet.Cache = nil // the cache only holds full probes
+
+	begin := time.Now()
+	results, hostErrs, scanned, stats, downErr := scanSources(ctx, p.sources, quiet, echoed, progressCh, nil)
+	phases := []ScanPhase{{Name: "discovery", Hosts: scanned, Found: len(results), Elapsed: time.Since(begin)}}
+	var limitErr error
+	if errors.Is(downErr, ErrHostLimit) {
+		limitErr, downErr = downErr, nil
+	}
+	if downErr != nil {
+		return results, hostErrs, scanned, stats, phases, downErr
+	}
+	if len(results) == 0 || len(loud.MethodOrder) == 0 {
+		return results, hostErrs, scanned, stats, phases, limitErr
+	}
+	if ctx.Err() != nil {
+		return results, hostErrs, max(0, scanned-len(results)), stats, phases, limitErr
+	}
+
+	alive := make([]net.IP, len(results))
+	for i, r := range results {
+		alive[i] = r.IP
+	}
+	loud.ARPSettle = 0 // every host in this pass is already found
+	loud.MaxHosts = 0
+	begin = time.Now()
+	ported, portErrs, probed, loudStats, downErr := scanSources(ctx, []hostSource{newListSource(alive)}, loud, nil, nil, nil)
+	phases = append(phases, ScanPhase{Name: "ports", Hosts: probed, Found: mergePorts(results, ported), Elapsed: time.Since(begin)})
+	if len(portErrs) > 0 {
+		hostErrs = append(hostErrs, portErrs...)
+		sort.Slice(hostErrs, func(i, j int) bool {
+			return ipToUint32(hostErrs[i].IP) < ipToUint32(hostErrs[j].IP)
+		})
+	}
+	if downErr == nil {
+		downErr = limitErr
+	}
+	return results, hostErrs, max(0, scanned-(len(alive)-probed)), stats.plus(loudStats), phases, downErr
+}
+
+// splitQuietMethods splits a probe order into the methods of the quiet
+// discovery pass (ICMP) and those of the port pass.
+func splitQuietMethods(order []string) (quiet, loud []string) {
+	for _, m := range order {
+		if m == MethodICMP {
+			quiet = append(quiet, m)
+		} else {
+			loud = append(loud, m)
+		}
+	}
+	return quiet, loud
+}
+
+// mergePorts copies the ports found by the port pass into the results of
+// the discovery pass and returns how many hosts answered it. Hosts the
+// port pass only found in the ARP table answered nothing.
+func mergePorts(results, ported []ScanResult) int {
+	byIP := make(map[string]*ScanResult, len(results))
+	for i := range results {
+		byIP[results[i].IP.String()] = &results[i]
+	}
+	found := 0
+	for _, q := range ported {
+		r := byIP[q.IP.String()]
+		if r == nil || q.Method == "ARP" {
+			continue
+		}
+		found++
+		r.OpenPorts, r.ClosedPorts, r.Banners, r.RTSP, r.RTT = q.OpenPorts, q.ClosedPorts, q.Banners, q.RTSP, q.RTT
+		for m, n := range q.Answers {
+			if r.Answers == nil {
+				r.Answers = make(map[string]int)
+			}
+			r.Answers[m] += n
+		}
+	}
+	return found
+}
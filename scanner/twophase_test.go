@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestTwoPhaseCancelledBetweenPhases cancels the scan once the discovery
+// pass has found its host, while the ARP table is settling, so the port
+// pass never runs. The report must not claim a complete scan.
+func TestTwoPhaseCancelledBetweenPhases(t *testing.T) {
+	plan, err := NewPlan(ScanOptions{
+		Targets:     []string{"127.0.0.1"},
+		TwoPhase:    true,
+		MethodOrder: []string{MethodICMP, MethodTCP},
+		Timeout:     200 * time.Millisecond,
+		ARPSettle:   3 * time.Second,
+		SkipDNS:     true,
+		SkipVendor:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Count the host as answering a broadcast ping, so the discovery pass
+	// finds it without the ping command.
+	plan.broadcast = func(context.Context) []net.IP { return []net.IP{net.IPv4(127, 0, 0, 1)} }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progressCh := make(chan Progress)
+	go func() {
+		for p := range progressCh {
+			if p.Found != nil {
+				cancel()
+			}
+		}
+	}()
+
+	begin := time.Now()
+	report, err := plan.Run(ctx, progressCh)
+	close(progressCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(begin) > 2*time.Second {
+		t.Errorf("Run took %v; the ARP settle time was not cut short", time.Since(begin))
+	}
+	if len(report.Phases) != 1 {
+		t.Errorf("got %d phases, want only discovery", len(report.Phases))
+	}
+	if report.Complete() {
+		t.Errorf("report is complete (scanned %d of %d)", report.Scanned, report.Total)
+	}
+	if report.Interrupted == nil {
+		t.Error("report.Interrupted is nil")
+	}
+}
+
+// TestTwoPhaseKeepsPortPassErrors sends the port pass from an address that
+// is not local, so every probe of the found host fails. Those errors must
+// be reported along with the discovery pass's.
+func TestTwoPhaseKeepsPortPassErrors(t *testing.T) {
+	plan, err := NewPlan(ScanOptions{
+		Targets:     []string{"127.0.0.1"},
+		TwoPhase:    true,
+		MethodOrder: []string{MethodICMP, MethodTCP},
+		Timeout:     200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := plan.probeOptions()
+	opts.SourceIP = net.ParseIP("203.0.113.9")
+	echoed := map[string]bool{"127.0.0.1": true} // found without a ping
+
+	results, hostErrs, _, _, _, _ := plan.twoPhase(context.Background(), opts, echoed, nil)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want the host found by the discovery pass", len(results))
+	}
+	if len(hostErrs) != 1 || !hostErrs[0].IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("host errors = %v, want the port pass's error for 127.0.0.1", hostErrs)
+	}
+}
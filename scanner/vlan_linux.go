@@ -0,0 +1,57 @@
+//go:build linux
+
+package scanner
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// vlanInterface returns the name of the 802.1Q subinterface for VLAN id
+// on parent, or on any interface if parent is "". localscan never builds
+// tagged frames itself: the kernel tags every probe sent through the
+// subinterface and untags the replies, so all probe methods, ARP
+// included, work on the VLAN. Creating the subinterface needs root, so it
+// is left to the user.
+func vlanInterface(parent string, id int) (string, error) {
+	out, err := exec.Command("ip", "-d", "-o", "link", "show", "type", "vlan").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot list VLAN interfaces: %v", err)
+	}
+	var matches []string
+	for _, line := range strings.Split(string(out), "\n") {
+		name, link, vid, ok := parseVLANLink(line)
+		if ok && vid == id && (parent == "" || link == parent) {
+			matches = append(matches, name)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) > 1:
+		return "", fmt.Errorf("VLAN %d is on several interfaces (%s); choose one with -interface", id, strings.Join(matches, ", "))
+	}
+	if parent == "" {
+		parent = "<trunk>"
+	}
+	return "", fmt.Errorf("no interface for VLAN %d on %s; create one as root with `ip link add link %s name %s.%d type vlan id %d`, bring it up and give it an address on the VLAN", id, parent, parent, parent, id, id)
+}
+
+// parseVLANLink parses a line of `ip -d -o link show type vlan` output,
+// e.g. "5: eth0.10@eth0: <...> ... vlan protocol 802.1Q id 10 <...> ...".
+func parseVLANLink(line string) (name, parent string, id int, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", "", 0, false
+	}
+	name, parent, _ = strings.Cut(strings.TrimSuffix(fields[1], ":"), "@")
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "id" && i > 0 && fields[i-1] == "802.1Q" {
+			id, err := strconv.Atoi(fields[i+1])
+			return name, parent, id, err == nil
+		}
+	}
+	return "", "", 0, false
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package scanner
+
+import "errors"
+
+// vlanInterface is not implemented on this platform.
+func vlanInterface(parent string, id int) (string, error) {
+	return "", errors.New("VLAN scanning is only supported on Linux")
+}